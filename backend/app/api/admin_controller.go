@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/internal/errors"
+	"cloud-clipboard/internal/events"
+	fileservice "cloud-clipboard/internal/file"
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/ratelimit"
+)
+
+// AdminController 管理控制器
+type AdminController struct {
+	fileService     *fileservice.FileService
+	hub             *events.Hub
+	downloadLimiter *ratelimit.Limiter
+	uploadLimiter   *ratelimit.Limiter
+}
+
+// NewAdminController 创建新的管理控制器
+func NewAdminController(fileService *fileservice.FileService, hub *events.Hub, downloadLimiter, uploadLimiter *ratelimit.Limiter) *AdminController {
+	return &AdminController{
+		fileService:     fileService,
+		hub:             hub,
+		downloadLimiter: downloadLimiter,
+		uploadLimiter:   uploadLimiter,
+	}
+}
+
+// GetThroughput 获取当前吞吐量
+// @Summary 获取当前吞吐量
+// @Description 获取上传和下载限速器中全局及各客户端令牌桶的配置和最近吞吐量（字节每秒）
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/admin/throughput [get]
+func (c *AdminController) GetThroughput(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"download": c.downloadLimiter.Stats(),
+		"upload":   c.uploadLimiter.Stats(),
+	})
+}
+
+// GetStorageUsage 获取存储用量
+// @Summary 获取存储用量
+// @Description 获取文件数量、去重后的存储对象数量，以及逻辑用量（所有文件大小之和）和物理用量（去重后实际占用）
+// @Tags admin
+// @Produce json
+// @Success 200 {object} fileservice.StorageUsage
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/storage [get]
+func (c *AdminController) GetStorageUsage(ctx *gin.Context) {
+	usage, err := c.fileService.GetStorageUsage()
+	if err != nil {
+		logger.Errorf("Failed to get storage usage: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeCheckStorageFailed,
+			"message": "检查总存储大小失败",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, usage)
+}
+
+// ScrubFiles 校验文件完整性
+// @Summary 校验文件完整性
+// @Description 立即重新读取所有存储内容并校验SHA-256摘要，返回已损坏和已恢复的文件ID
+// @Tags admin
+// @Produce json
+// @Success 200 {object} fileservice.ScrubResult
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/scrub [post]
+func (c *AdminController) ScrubFiles(ctx *gin.Context) {
+	result, err := c.fileService.ScrubFiles()
+	if err != nil {
+		logger.Errorf("Failed to scrub files: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeScrubFilesFailed,
+			"message": "校验文件完整性失败",
+		})
+		return
+	}
+
+	for owner, ids := range result.CorruptedByOwner() {
+		c.hub.Publish(owner, events.TypeFileCorrupt, gin.H{"ids": ids})
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/internal/auth"
+	"cloud-clipboard/internal/errors"
+	"cloud-clipboard/internal/logger"
+)
+
+// identityKey gin上下文中保存请求身份的键
+const identityKey = "identity"
+
+// Authenticate 认证中间件，从Authorization: Bearer请求头解析API令牌并把身份保存到gin上下文，
+// EventSource和下载链接等无法设置请求头的场景可以使用access_token查询参数；
+// 未携带令牌的请求使用anonymousScopes作为匿名身份的权限，携带无效令牌的请求直接拒绝
+func Authenticate(tokens *auth.TokenStore, anonymousScopes []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		secret := bearerToken(ctx)
+		if secret == "" {
+			ctx.Set(identityKey, auth.Anonymous(anonymousScopes))
+			ctx.Next()
+			return
+		}
+
+		identity, err := tokens.Authenticate(secret)
+		if err == auth.ErrInvalidToken {
+			logger.Warnf("Invalid API token from %s", ctx.ClientIP())
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    errors.ErrCodeInvalidToken,
+				"message": "令牌无效或已过期",
+			})
+			return
+		}
+		if err != nil {
+			logger.Errorf("Failed to authenticate token: %v", err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"code":    errors.ErrCodeAuthFailed,
+				"message": "身份认证失败",
+			})
+			return
+		}
+
+		ctx.Set(identityKey, identity)
+		ctx.Next()
+	}
+}
+
+// RequireScope 权限检查中间件，要求请求身份拥有scopes中的任一权限（admin拥有所有权限）
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		identity := CurrentIdentity(ctx)
+		if identity.HasScope(scopes...) {
+			ctx.Next()
+			return
+		}
+
+		logger.Warnf("Permission denied for %s: %s %s requires %s", identity, ctx.Request.Method, ctx.FullPath(), strings.Join(scopes, "|"))
+		if identity.IsAnonymous() {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    errors.ErrCodeAuthRequired,
+				"message": "需要身份认证",
+			})
+			return
+		}
+
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"code":    errors.ErrCodeInsufficientScope,
+			"message": "权限不足",
+		})
+	}
+}
+
+// CurrentIdentity 获取当前请求的身份，没有经过认证中间件时返回没有任何权限的匿名身份
+func CurrentIdentity(ctx *gin.Context) *auth.Identity {
+	if v, ok := ctx.Get(identityKey); ok {
+		if identity, ok := v.(*auth.Identity); ok {
+			return identity
+		}
+	}
+
+	return auth.Anonymous(nil)
+}
+
+// bearerToken 获取请求携带的令牌
+func bearerToken(ctx *gin.Context) string {
+	if token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	return ctx.Query("access_token")
+}
+
+// currentNamespace 当前请求所属的命名空间，剪切板、文件和事件都按命名空间隔离，公共命名空间为空字符串
+func currentNamespace(ctx *gin.Context) string {
+	return CurrentIdentity(ctx).Namespace
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	fileservice "cloud-clipboard/internal/file"
+)
+
+// errInvalidChecksum 客户端提供的校验和格式无效
+var errInvalidChecksum = stderrors.New("invalid checksum header")
+
+// expectedDigest 从请求头获取客户端期望的SHA-256摘要（十六进制），
+// 支持X-Content-SHA256（十六进制）和Digest: sha-256=<base64>两种格式，未提供时返回空字符串
+func expectedDigest(header http.Header) (string, error) {
+	if value := strings.TrimSpace(header.Get("X-Content-SHA256")); value != "" {
+		sum, err := hex.DecodeString(value)
+		if err != nil || len(sum) != 32 {
+			return "", errInvalidChecksum
+		}
+		return hex.EncodeToString(sum), nil
+	}
+
+	for _, item := range strings.Split(header.Get("Digest"), ",") {
+		algorithm, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || !strings.EqualFold(algorithm, "sha-256") {
+			continue
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != 32 {
+			return "", errInvalidChecksum
+		}
+		return hex.EncodeToString(sum), nil
+	}
+
+	return "", nil
+}
+
+// digestHeader 生成文件的Digest响应头，没有摘要时返回空字符串
+func digestHeader(file *fileservice.FileMetadata) string {
+	sum, err := hex.DecodeString(file.Digest)
+	if err != nil || len(sum) == 0 {
+		return ""
+	}
+
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum)
+}
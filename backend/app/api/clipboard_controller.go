@@ -1,32 +1,83 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
 	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/auth"
 	"cloud-clipboard/internal/clipboard"
+	"cloud-clipboard/internal/events"
+	"cloud-clipboard/internal/logger"
 )
 
-// ClipboardController 字符串剪切板控制器
+// ClipboardController 字符串剪切板控制器，每个命名空间使用独立的剪切板
 type ClipboardController struct {
-	cache  *clipboard.LRUCache
+	spaces *clipboard.Namespaces
+	hub    *events.Hub
 	config *config.ClipboardConfig
 }
 
 // NewClipboardController 创建新的字符串剪切板控制器
-func NewClipboardController(cache *clipboard.LRUCache, config *config.ClipboardConfig) *ClipboardController {
+func NewClipboardController(spaces *clipboard.Namespaces, hub *events.Hub, config *config.ClipboardConfig) *ClipboardController {
 	return &ClipboardController{
-		cache:  cache,
+		spaces: spaces,
+		hub:    hub,
 		config: config,
 	}
 }
 
+// cache 获取当前请求所属命名空间的剪切板，失败时已写入响应并返回false
+func (c *ClipboardController) cache(ctx *gin.Context) (clipboard.Store, bool) {
+	return c.namespaceCache(ctx, currentNamespace(ctx), CurrentIdentity(ctx).NamespaceName())
+}
+
+// namespaceCache 获取命名空间namespace（显示名称为name）的剪切板，失败时已写入响应并返回false
+func (c *ClipboardController) namespaceCache(ctx *gin.Context, namespace, name string) (clipboard.Store, bool) {
+	cache, err := c.spaces.Get(namespace)
+	if err != nil {
+		logger.Errorf("Failed to open clipboard of namespace %s: %v", name, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to open clipboard",
+		})
+		return nil, false
+	}
+
+	return cache, true
+}
+
+// existingCache 获取已存在的命名空间namespace（显示名称为name）的剪切板，不存在时返回404，
+// 失败时已写入响应并返回false
+func (c *ClipboardController) existingCache(ctx *gin.Context, namespace, name string) (clipboard.Store, bool) {
+	cache, err := c.spaces.Lookup(namespace)
+	if err == clipboard.ErrNamespaceNotFound {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": "Namespace not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		logger.Errorf("Failed to open clipboard of namespace %s: %v", name, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to open clipboard",
+		})
+		return nil, false
+	}
+
+	return cache, true
+}
+
 // UploadTextRequest 上传字符串请求
 type UploadTextRequest struct {
 	Text string `json:"text" binding:"required"`
+	// ExpiresIn 有效期（毫秒），为空或0表示永不过期
+	ExpiresIn int64 `json:"expiresIn"`
+	// ReadOnce 阅后即焚，首次通过ID读取后立即删除
+	ReadOnce bool `json:"readOnce"`
 }
 
 // UploadText 上传字符串
@@ -57,43 +108,84 @@ func (c *ClipboardController) UploadText(ctx *gin.Context) {
 		return
 	}
 
+	// 检查有效期
+	if req.ExpiresIn < 0 || (c.config.MaxExpiresIn > 0 && req.ExpiresIn > c.config.MaxExpiresIn) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid expiration time",
+		})
+		return
+	}
+
+	cache, ok := c.cache(ctx)
+	if !ok {
+		return
+	}
+
+	opts := clipboard.ItemOptions{ReadOnce: req.ReadOnce}
+	if req.ExpiresIn > 0 {
+		opts.ExpiresAt = time.Now().UnixMilli() + req.ExpiresIn
+	}
+
 	id := uuid.New().String()
-	if err := c.cache.Put(id, req.Text); err != nil {
+	if err := cache.PutWithOptions(id, req.Text, opts); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
-		"id":      id,
-		"text":    req.Text,
-		"size":    len([]byte(req.Text)),
-		"message": "Text uploaded successfully",
-	})
+	// 阅后即焚的内容不随事件推送
+	item := &clipboard.CacheItem{
+		Key:       id,
+		Value:     req.Text,
+		Size:      int64(len([]byte(req.Text))),
+		ExpiresAt: opts.ExpiresAt,
+		ReadOnce:  opts.ReadOnce,
+	}
+	if item.ReadOnce {
+		item.Value = ""
+	}
+	c.hub.Publish(currentNamespace(ctx), events.TypeClipboardUpload, item)
+
+	response := gin.H{
+		"id":       id,
+		"text":     req.Text,
+		"size":     len([]byte(req.Text)),
+		"readOnce": req.ReadOnce,
+		"message":  "Text uploaded successfully",
+	}
+	if opts.ExpiresAt > 0 {
+		response["expiresAt"] = opts.ExpiresAt
+	}
+
+	ctx.JSON(http.StatusCreated, response)
 }
 
 // GetAllText 获取所有字符串
 // @Summary 获取所有字符串
-// @Description 获取所有字符串（按最近访问排序）
+// @Description 获取当前命名空间的所有字符串（按最近访问排序）
 // @Tags clipboard
 // @Produce json
 // @Success 200 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/clipboard/text [get]
 func (c *ClipboardController) GetAllText(ctx *gin.Context) {
-	items := c.cache.GetAll()
+	cache, ok := c.cache(ctx)
+	if !ok {
+		return
+	}
 
 	ctx.JSON(http.StatusOK, gin.H{
-		"items":      items,
-		"totalSize":  c.cache.GetSize(),
-		"totalItems": c.cache.GetCount(),
+		"items":      cache.GetAll(),
+		"totalSize":  cache.GetSize(),
+		"totalItems": cache.GetCount(),
+		"namespace":  CurrentIdentity(ctx).NamespaceName(),
 	})
 }
 
 // GetTextById 获取指定字符串
 // @Summary 获取指定字符串
-// @Description 根据ID获取指定字符串
+// @Description 根据ID获取指定字符串，阅后即焚的字符串在本次读取后被删除
 // @Tags clipboard
 // @Produce json
 // @Param id path string true "字符串ID"
@@ -104,7 +196,12 @@ func (c *ClipboardController) GetAllText(ctx *gin.Context) {
 func (c *ClipboardController) GetTextById(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	text, ok := c.cache.Get(id)
+	cache, ok := c.cache(ctx)
+	if !ok {
+		return
+	}
+
+	item, ok := cache.Get(id)
 	if !ok {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"error": "Text not found",
@@ -112,10 +209,74 @@ func (c *ClipboardController) GetTextById(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"id":   id,
-		"text": text,
-	})
+	// 阅后即焚的内容在读取后已被删除
+	if item.ReadOnce {
+		c.hub.Publish(currentNamespace(ctx), events.TypeClipboardDelete, gin.H{"id": id})
+	}
+
+	response := gin.H{
+		"id":       id,
+		"text":     item.Value,
+		"readOnce": item.ReadOnce,
+	}
+	if item.ExpiresAt > 0 {
+		response["expiresAt"] = item.ExpiresAt
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// WaitNextText 等待下一条字符串
+// @Summary 等待下一条字符串
+// @Description 长轮询，阻塞直到after之后有新字符串写入或超时
+// @Tags clipboard
+// @Produce json
+// @Param after query string false "最后收到的字符串ID，为空时等待下一次写入"
+// @Param timeout query string false "等待超时时间，如30s"
+// @Success 200 {object} map[string]interface{}
+// @Success 204 "等待超时"
+// @Failure 400 {object} map[string]interface{}
+// @Router /api/clipboard/text/next [get]
+func (c *ClipboardController) WaitNextText(ctx *gin.Context) {
+	timeout := time.Duration(c.config.MaxWaitTimeout) * time.Millisecond
+	if value := ctx.Query("timeout"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid timeout",
+			})
+			return
+		}
+		if d < timeout {
+			timeout = d
+		}
+	}
+
+	cache, ok := c.cache(ctx)
+	if !ok {
+		return
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+	defer cancel()
+
+	item, ok := cache.WaitNext(waitCtx, ctx.Query("after"))
+	if !ok {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+
+	response := gin.H{
+		"id":       item.Key,
+		"text":     item.Value,
+		"size":     item.Size,
+		"readOnce": item.ReadOnce,
+	}
+	if item.ExpiresAt > 0 {
+		response["expiresAt"] = item.ExpiresAt
+	}
+
+	ctx.JSON(http.StatusOK, response)
 }
 
 // DeleteTextById 删除指定字符串
@@ -131,13 +292,29 @@ func (c *ClipboardController) GetTextById(ctx *gin.Context) {
 func (c *ClipboardController) DeleteTextById(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	if !c.cache.Delete(id) {
+	cache, ok := c.cache(ctx)
+	if !ok {
+		return
+	}
+
+	deleted, err := cache.Delete(id)
+	if err != nil {
+		logger.Errorf("Failed to delete text %s: %v", id, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete text",
+		})
+		return
+	}
+	if !deleted {
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"error": "Text not found",
 		})
 		return
 	}
 
+	logger.Infof("Text %s deleted by %s", id, CurrentIdentity(ctx))
+	c.hub.Publish(currentNamespace(ctx), events.TypeClipboardDelete, gin.H{"id": id})
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "Text deleted successfully",
 	})
@@ -145,14 +322,44 @@ func (c *ClipboardController) DeleteTextById(ctx *gin.Context) {
 
 // ClearAllText 清空所有字符串
 // @Summary 清空所有字符串
-// @Description 清空剪切板中的所有字符串
+// @Description 清空命名空间剪切板中的所有字符串，默认为管理员令牌所属的命名空间，可以通过namespace参数指定其他已存在的命名空间
 // @Tags clipboard
 // @Produce json
+// @Param namespace query string false "要清空的命名空间名称，public表示公共命名空间"
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/clipboard/text [delete]
 func (c *ClipboardController) ClearAllText(ctx *gin.Context) {
-	c.cache.Clear()
+	namespace, name := currentNamespace(ctx), CurrentIdentity(ctx).NamespaceName()
+	open := c.namespaceCache
+	if requested, ok := ctx.GetQuery("namespace"); ok {
+		resolved, err := auth.ResolveNamespace(requested)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid namespace",
+			})
+			return
+		}
+		// 指定的命名空间只查找不创建，避免为任意名称创建存储和目录
+		namespace, name, open = resolved, requested, c.existingCache
+	}
+
+	cache, ok := open(ctx, namespace, name)
+	if !ok {
+		return
+	}
+
+	if err := cache.Clear(); err != nil {
+		logger.Errorf("Failed to clear text items: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to clear text items",
+		})
+		return
+	}
+	logger.Infof("All text items in namespace %s cleared by %s", name, CurrentIdentity(ctx))
+	c.hub.Publish(namespace, events.TypeClipboardClear, nil)
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "All text items cleared successfully",
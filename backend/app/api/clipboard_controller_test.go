@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/clipboard"
+	"cloud-clipboard/internal/events"
+	"cloud-clipboard/internal/share"
+)
+
+func TestUnknownNamespaceIsNotCreated(t *testing.T) {
+	opened := map[string]int{}
+	spaces := clipboard.NewNamespaces(func(namespace string) (clipboard.Store, error) {
+		opened[namespace]++
+		return clipboard.NewLRUCache(1024, 10), nil
+	}, nil)
+	hub := events.NewHub(0)
+	clipboards := NewClipboardController(spaces, hub, &config.ClipboardConfig{})
+
+	signer, err := share.NewSigner("test-secret", "")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	shares, err := share.NewStore(filepath.Join(t.TempDir(), "shares.json"), signer)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	files, _ := newTestFileController(t)
+	c := NewShareController(shares, files, clipboards, hub, &config.ShareConfig{}, &config.ServerConfig{})
+
+	r := gin.New()
+	r.DELETE("/api/clipboard/text", clipboards.ClearAllText)
+	r.GET("/s/:token", c.RedeemShare)
+
+	// 清空不存在的命名空间
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/clipboard/text?namespace=ghost", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("clear unknown namespace: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	// 兑换指向不存在的命名空间的字符串链接
+	_, token, err := shares.Create(share.KindText, "text-id", "ghost", "", share.Policy{
+		ExpiresIn: int64(time.Hour / time.Millisecond),
+		MaxUses:   1,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/s/"+token, nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("redeem link of unknown namespace: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	if opened["ghost"] != 0 {
+		t.Fatalf("store for unknown namespace opened %d times", opened["ghost"])
+	}
+
+	// 已打开的命名空间可以清空
+	if _, err := spaces.Get("team"); err != nil {
+		t.Fatalf("Get team: %v", err)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/clipboard/text?namespace=team", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("clear existing namespace: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
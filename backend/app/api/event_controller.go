@@ -0,0 +1,181 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/events"
+	"cloud-clipboard/internal/logger"
+)
+
+// wsWriteTimeout WebSocket单次写入超时时间
+const wsWriteTimeout = 10 * time.Second
+
+// EventController 实时事件控制器
+type EventController struct {
+	hub      *events.Hub
+	config   *config.EventConfig
+	upgrader websocket.Upgrader
+}
+
+// NewEventController 创建新的实时事件控制器
+func NewEventController(hub *events.Hub, config *config.EventConfig) *EventController {
+	return &EventController{
+		hub:    hub,
+		config: config,
+		upgrader: websocket.Upgrader{
+			// 跨域策略与CORS配置保持一致，允许任意来源
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Stream 订阅实时事件
+// @Summary 订阅实时事件
+// @Description 通过SSE或WebSocket推送当前命名空间中剪切板和文件的变更事件，支持通过Last-Event-ID断线续传
+// @Tags events
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "最后收到的事件ID"
+// @Param lastEventId query string false "最后收到的事件ID（WebSocket无法设置请求头时使用）"
+// @Success 200 {object} events.Event
+// @Router /api/events [get]
+func (c *EventController) Stream(ctx *gin.Context) {
+	lastEventID := c.lastEventID(ctx)
+
+	if websocket.IsWebSocketUpgrade(ctx.Request) {
+		c.serveWebSocket(ctx, lastEventID)
+		return
+	}
+
+	c.serveSSE(ctx, lastEventID)
+}
+
+// lastEventID 获取客户端最后收到的事件ID
+func (c *EventController) lastEventID(ctx *gin.Context) uint64 {
+	value := ctx.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = ctx.Query("lastEventId")
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// serveSSE 通过SSE推送事件
+func (c *EventController) serveSSE(ctx *gin.Context, lastEventID uint64) {
+	sub, missed := c.hub.Subscribe(currentNamespace(ctx), lastEventID)
+	defer sub.Close()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	for _, event := range missed {
+		c.renderSSE(ctx, event)
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(time.Duration(c.config.HeartbeatInterval) * time.Millisecond)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			c.renderSSE(ctx, event)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(ctx.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// renderSSE 输出单个SSE事件
+func (c *EventController) renderSSE(ctx *gin.Context, event *events.Event) {
+	ctx.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
+
+// serveWebSocket 通过WebSocket推送事件
+func (c *EventController) serveWebSocket(ctx *gin.Context, lastEventID uint64) {
+	conn, err := c.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// Upgrade失败时已向客户端返回错误
+		logger.Warnf("Failed to upgrade event stream to websocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub, missed := c.hub.Subscribe(currentNamespace(ctx), lastEventID)
+	defer sub.Close()
+
+	// 读取循环用于处理控制帧并检测客户端断开
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range missed {
+		if err := c.writeWebSocket(conn, event); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(time.Duration(c.config.HeartbeatInterval) * time.Millisecond)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// 消费过慢被断开，通知客户端重连续传
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			if err := c.writeWebSocket(conn, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeWebSocket 输出单个WebSocket事件
+func (c *EventController) writeWebSocket(conn *websocket.Conn, event *events.Event) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(event)
+}
@@ -1,72 +1,127 @@
 package api
 
 import (
+	stderrors "errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"cloud-clipboard/app/config"
 	"cloud-clipboard/internal/errors"
+	"cloud-clipboard/internal/events"
 	fileservice "cloud-clipboard/internal/file"
 	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/preview"
+	"cloud-clipboard/internal/ratelimit"
+	"cloud-clipboard/internal/storage"
+	"cloud-clipboard/internal/thumbnail"
 )
 
 // FileController 文件控制器
 type FileController struct {
-	fileService *fileservice.FileService
-	config      *config.FileConfig
+	fileService     *fileservice.FileService
+	hub             *events.Hub
+	downloadLimiter *ratelimit.Limiter
+	passwords       *passwordGuard
+	config          *config.FileConfig
 }
 
+// errOpenFile 打开文件内容失败，此时还没有向客户端发送任何内容
+var errOpenFile = stderrors.New("failed to open file")
+
 // NewFileController 创建新的文件控制器
-func NewFileController(fileService *fileservice.FileService, config *config.FileConfig) *FileController {
+func NewFileController(fileService *fileservice.FileService, hub *events.Hub, downloadLimiter *ratelimit.Limiter, config *config.FileConfig) *FileController {
 	return &FileController{
-		fileService: fileService,
-		config:      config,
+		fileService:     fileService,
+		hub:             hub,
+		downloadLimiter: downloadLimiter,
+		passwords:       newPasswordGuard(),
+		config:          config,
 	}
 }
 
 // UploadFile 上传文件
 // @Summary 上传文件
-// @Description 上传文件到服务器
+// @Description 上传文件到服务器，文件类型根据内容检测，客户端声明的Content-Type只作为declaredMimetype记录。
+// @Description 文件内容直接从请求体流式写入存储，超过大小限制时立即终止。
+// @Description 分享策略字段必须放在file字段之前，之后的字段会被忽略
 // @Tags files
 // @Accept multipart/form-data
 // @Produce json
+// @Param expiresIn formData int false "有效期（毫秒），默认为maxAge，不能超过maxExpiresIn"
+// @Param maxDownloads formData int false "最大下载次数，0表示不限制，不能超过downloadCeiling"
+// @Param password formData string false "访问密码，下载、预览和缩略图需要通过X-File-Password请求头提供"
 // @Param file formData file true "要上传的文件"
+// @Param X-Content-SHA256 header string false "期望的SHA-256摘要（十六进制），也可以使用Digest: sha-256=<base64>"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/files [post]
 func (c *FileController) UploadFile(ctx *gin.Context) {
-	// 获取上传的文件，不使用http.MaxBytesReader，因为它会关闭连接
-	file, header, err := ctx.Request.FormFile("file")
+	// 请求体明显超过限制时直接拒绝，不读取内容
+	if ctx.Request.ContentLength > c.config.MaxFileSize+multipartOverhead {
+		logger.Warnf("Upload request too large: %d, max allowed: %d", ctx.Request.ContentLength, c.config.MaxFileSize)
+		c.fileTooLarge(ctx)
+		return
+	}
+
+	// 客户端可以提供期望的SHA-256摘要，内容不一致时拒绝
+	digest, err := expectedDigest(ctx.Request.Header)
 	if err != nil {
-		logger.Errorf("Failed to get file from request: %v", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{
-			"code":    errors.ErrCodeFileSizeExceeded,
-			"message": fmt.Sprintf("文件大小超过限制（最大%vMB）", c.config.MaxFileSize/(1024*1024)),
+			"code":    errors.ErrCodeInvalidChecksum,
+			"message": "校验和格式无效",
 		})
 		return
 	}
-	defer file.Close()
 
-	// 检查文件大小
-	if header.Size > c.config.MaxFileSize {
-		logger.Warnf("File size exceeds maximum limit: %d, max allowed: %d", header.Size, c.config.MaxFileSize)
+	// 直接读取multipart流，不在内存或临时文件中缓存整个文件
+	mr, err := ctx.Request.MultipartReader()
+	if err != nil {
+		c.malformedUpload(ctx, err)
+		return
+	}
+	part, fields, err := nextFilePart(mr)
+	if err != nil {
+		c.malformedUpload(ctx, err)
+		return
+	}
+	defer part.Close()
+
+	// 上传者设置的有效期、下载次数和访问密码
+	policy, err := parseSharePolicy(fields, c.config)
+	if err != nil {
+		handleSharePolicyError(ctx, err)
+		return
+	}
+
+	// 读完之前无法知道文件大小，按请求体大小（不超过单文件上限）预留存储空间
+	reserve := c.config.MaxFileSize
+	if ctx.Request.ContentLength > 0 && ctx.Request.ContentLength < reserve {
+		reserve = ctx.Request.ContentLength
+	}
+	release, err := c.fileService.ReserveStorage(currentNamespace(ctx), reserve, c.config.MaxStorage, c.config.NamespaceQuota)
+	if err == fileservice.ErrStorageExceeded {
+		logger.Warnf("Total storage limit exceeded, requested reservation: %d", reserve)
 		ctx.JSON(http.StatusBadRequest, gin.H{
-			"code":    errors.ErrCodeFileSizeExceeded,
-			"message": fmt.Sprintf("文件大小超过限制（最大%vMB）", c.config.MaxFileSize/(1024*1024)),
+			"code":    errors.ErrCodeTotalStorageExceeded,
+			"message": "总存储容量超过限制",
 		})
 		return
 	}
-
-	// 检查总存储限制
-	totalStorage, err := c.fileService.CheckTotalStorage()
+	if err == fileservice.ErrQuotaExceeded {
+		logger.Warnf("Storage quota of namespace %s exceeded, requested reservation: %d", CurrentIdentity(ctx).NamespaceName(), reserve)
+		namespaceQuotaExceeded(ctx)
+		return
+	}
 	if err != nil {
 		logger.Errorf("Failed to check total storage: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -75,34 +130,45 @@ func (c *FileController) UploadFile(ctx *gin.Context) {
 		})
 		return
 	}
+	defer release()
+
+	// 写入文件内容并添加文件元数据，相同内容只存储一份；失败时已写入的部分内容会被删除
+	body := &uploadLimitReader{r: part, limit: c.config.MaxFileSize}
+	fileInfo := &fileservice.FileInfo{
+		Owner:          currentNamespace(ctx),
+		OriginalName:   part.FileName(),
+		Size:           -1,
+		Mimetype:       part.Header.Get("Content-Type"),
+		ExpectedDigest: digest,
+		SharePolicy:    policy,
+	}
 
-	if totalStorage+header.Size > c.config.MaxStorage {
-		logger.Warnf("Total storage limit exceeded. Current: %d, Max: %d, New file: %d", totalStorage, c.config.MaxStorage, header.Size)
+	metadata, err := c.fileService.AddFile(body, fileInfo)
+	if body.exceeded {
+		logger.Warnf("File size exceeds maximum limit: %s, max allowed: %d", fileInfo.OriginalName, c.config.MaxFileSize)
+		c.fileTooLarge(ctx)
+		return
+	}
+	if body.readErr != nil {
+		c.malformedUpload(ctx, body.readErr)
+		return
+	}
+	if err == fileservice.ErrFileTypeNotAllowed {
 		ctx.JSON(http.StatusBadRequest, gin.H{
-			"code":    errors.ErrCodeTotalStorageExceeded,
-			"message": "总存储容量超过限制",
+			"code":    errors.ErrCodeInvalidFileFormat,
+			"message": "不允许上传该类型的文件",
 		})
 		return
 	}
-
-	// 创建唯一文件名
-	uniqueFilename := fmt.Sprintf("%d-%s", time.Now().UnixNano(), header.Filename)
-	filePath := filepath.Join(c.config.UploadDir, uniqueFilename)
-
-	// 创建目标文件
-	dst, err := os.Create(filePath)
-	if err != nil {
-		logger.Errorf("Failed to create file: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"code":    errors.ErrCodeCreateFileFailed,
-			"message": "创建文件失败",
+	if err == fileservice.ErrChecksumMismatch {
+		logger.Warnf("File checksum mismatch: %s, expected: %s", fileInfo.OriginalName, digest)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeChecksumMismatch,
+			"message": "文件内容与校验和不一致",
 		})
 		return
 	}
-	defer dst.Close()
-
-	// 复制文件内容
-	if _, err := io.Copy(dst, file); err != nil {
+	if err != nil {
 		logger.Errorf("Failed to save file: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    errors.ErrCodeSaveFileFailed,
@@ -111,47 +177,60 @@ func (c *FileController) UploadFile(ctx *gin.Context) {
 		return
 	}
 
-	// 添加文件元数据
-	fileInfo := &fileservice.FileInfo{
-		OriginalName: header.Filename,
-		Size:         header.Size,
-		Mimetype:     header.Header.Get("Content-Type"),
-		Path:         filePath,
-		MaxDownloads: c.config.MaxDownloads,
-	}
-
-	metadata, err := c.fileService.AddFileMetadata(fileInfo)
-	if err != nil {
-		logger.Errorf("Failed to add file metadata: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"code":    errors.ErrCodeAddMetadataFailed,
-			"message": "添加文件元数据失败",
-		})
-		return
+	fileData := map[string]interface{}{
+		"id":           metadata.ID,
+		"filename":     metadata.Filename,
+		"size":         metadata.Size,
+		"mimetype":     metadata.Mimetype,
+		"digest":       metadata.Digest,
+		"uploadTime":   metadata.UploadTime,
+		"expiresAt":    metadata.ExpiresAt,
+		"maxDownloads": metadata.MaxDownloads,
+		"protected":    metadata.Protected(),
 	}
+	c.hub.Publish(metadata.Owner, events.TypeFileUpload, fileData)
 
 	ctx.JSON(http.StatusCreated, gin.H{
 		"message": "File uploaded successfully",
-		"file": map[string]interface{}{
-			"id":         metadata.ID,
-			"filename":   metadata.Filename,
-			"size":       metadata.Size,
-			"mimetype":   metadata.Mimetype,
-			"uploadTime": metadata.UploadTime,
-		},
+		"file":    fileData,
+	})
+}
+
+// fileTooLarge 返回文件大小超过限制的响应
+func (c *FileController) fileTooLarge(ctx *gin.Context) {
+	ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"code":    errors.ErrCodeFileSizeExceeded,
+		"message": fmt.Sprintf("文件大小超过限制（最大%vMB）", c.config.MaxFileSize/(1024*1024)),
+	})
+}
+
+// namespaceQuotaExceeded 返回命名空间存储容量超过限制的响应
+func namespaceQuotaExceeded(ctx *gin.Context) {
+	ctx.JSON(http.StatusBadRequest, gin.H{
+		"code":    errors.ErrCodeNamespaceQuotaExceeded,
+		"message": "命名空间存储容量超过限制",
+	})
+}
+
+// malformedUpload 返回上传请求格式无效的响应
+func (c *FileController) malformedUpload(ctx *gin.Context, err error) {
+	logger.Warnf("Malformed upload request: %v", err)
+	ctx.JSON(http.StatusBadRequest, gin.H{
+		"code":    errors.ErrCodeMalformedUpload,
+		"message": "上传请求格式无效或缺少file字段",
 	})
 }
 
 // GetAllFiles 获取所有文件
 // @Summary 获取所有文件
-// @Description 获取所有文件列表
+// @Description 获取当前命名空间的文件列表
 // @Tags files
 // @Produce json
 // @Success 200 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/files [get]
 func (c *FileController) GetAllFiles(ctx *gin.Context) {
-	files, err := c.fileService.GetAllFileMetadata()
+	files, err := c.fileService.GetAllFileMetadata(currentNamespace(ctx))
 	if err != nil {
 		logger.Errorf("Failed to get all files: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -165,14 +244,19 @@ func (c *FileController) GetAllFiles(ctx *gin.Context) {
 	result := make([]map[string]interface{}, 0)
 	for _, file := range files {
 		result = append(result, map[string]interface{}{
-			"id":             file.ID,
-			"filename":       file.Filename,
-			"size":           file.Size,
-			"mimetype":       file.Mimetype,
-			"uploadTime":     file.UploadTime,
-			"lastAccessTime": file.LastAccessTime,
-			"downloadCount":  file.DownloadCount,
-			"maxDownloads":   file.MaxDownloads,
+			"id":               file.ID,
+			"filename":         file.Filename,
+			"size":             file.Size,
+			"mimetype":         file.Mimetype,
+			"declaredMimetype": file.DeclaredMimetype,
+			"uploadTime":       file.UploadTime,
+			"lastAccessTime":   file.LastAccessTime,
+			"downloadCount":    file.DownloadCount,
+			"maxDownloads":     file.MaxDownloads,
+			"digest":           file.Digest,
+			"corrupt":          file.Corrupt,
+			"expiresAt":        file.ExpiresAt,
+			"protected":        file.Protected(),
 		})
 	}
 
@@ -194,7 +278,7 @@ func (c *FileController) GetAllFiles(ctx *gin.Context) {
 func (c *FileController) GetFileInfo(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	file, err := c.fileService.GetFileMetadata(id)
+	file, err := c.fileService.GetFileMetadata(currentNamespace(ctx), id)
 	if err != nil {
 		if err == fileservice.ErrFileNotFound {
 			ctx.JSON(http.StatusNotFound, gin.H{
@@ -212,33 +296,51 @@ func (c *FileController) GetFileInfo(ctx *gin.Context) {
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
-		"id":             file.ID,
-		"filename":       file.Filename,
-		"size":           file.Size,
-		"mimetype":       file.Mimetype,
-		"uploadTime":     file.UploadTime,
-		"lastAccessTime": file.LastAccessTime,
-		"downloadCount":  file.DownloadCount,
-		"maxDownloads":   file.MaxDownloads,
+		"id":               file.ID,
+		"filename":         file.Filename,
+		"size":             file.Size,
+		"mimetype":         file.Mimetype,
+		"declaredMimetype": file.DeclaredMimetype,
+		"uploadTime":       file.UploadTime,
+		"lastAccessTime":   file.LastAccessTime,
+		"downloadCount":    file.DownloadCount,
+		"maxDownloads":     file.MaxDownloads,
+		"digest":           file.Digest,
+		"corrupt":          file.Corrupt,
+		"verifiedAt":       file.VerifiedAt,
+		"expiresAt":        file.ExpiresAt,
+		"protected":        file.Protected(),
 	})
 }
 
 // DownloadFile 下载文件
 // @Summary 下载文件
-// @Description 根据ID下载文件（带速度限制）
+// @Description 根据ID下载文件（按全局和客户端带宽限速），支持Range断点续传和多范围请求，支持ETag/Last-Modified条件请求。
+// @Description 每次请求计为一次下载；传输未到达文件末尾时记录中断位置，同一客户端1小时内从已发送范围内的位置发起的单范围请求视为续传，不再计数，
+// @Description 不限下载次数的文件不包含首字节的范围请求在文件已被下载过时同样不计数。返回304的条件请求不计数。
 // @Tags files
 // @Produce octet-stream
 // @Param id path string true "文件ID"
+// @Param X-File-Password header string false "访问密码"
+// @Param Range header string false "请求的字节范围，例如bytes=0-1023"
+// @Param If-Range header string false "ETag或Last-Modified，不匹配时忽略Range"
+// @Param If-None-Match header string false "客户端缓存的ETag"
 // @Success 200 {file} file
+// @Success 206 {file} file
+// @Success 304
+// @Header 200 {string} Digest "文件内容的SHA-256摘要，格式为sha-256=<base64>"
+// @Failure 401 {object} map[string]interface{}
 // @Failure 403 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Failure 416 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/files/{id}/download [get]
 func (c *FileController) DownloadFile(ctx *gin.Context) {
 	id := ctx.Param("id")
 
 	// 获取文件元数据
-	file, err := c.fileService.GetFileMetadata(id)
+	file, err := c.fileService.GetFileMetadata(currentNamespace(ctx), id)
 	if err != nil {
 		if err == fileservice.ErrFileNotFound {
 			ctx.JSON(http.StatusNotFound, gin.H{
@@ -255,21 +357,33 @@ func (c *FileController) DownloadFile(ctx *gin.Context) {
 		return
 	}
 
-	// 检查下载次数
-	if file.DownloadCount >= file.MaxDownloads {
-		logger.Warnf("File download limit reached: %s, current: %d, max: %d", id, file.DownloadCount, file.MaxDownloads)
-		ctx.JSON(http.StatusForbidden, gin.H{
-			"code":    errors.ErrCodeDownloadLimitReached,
-			"message": "文件下载次数已达上限",
-		})
+	// 检查有效期和访问密码
+	if !c.checkFileAccess(ctx, file) {
 		return
 	}
 
+	c.serveDownload(ctx, file, nil)
+}
+
+// downloadHook 下载次数之外的额外计数（分享链接的使用次数），在预留下载次数之后获取，下载失败时与下载次数一起退还
+type downloadHook struct {
+	// acquire 参数表示请求是否为服务端确认的续传，返回false表示已写入响应并终止下载
+	acquire func(resumed bool) bool
+	// release 退还acquire占用的次数
+	release func()
+}
+
+// serveDownload 返回文件内容，调用方已检查访问权限；hook不为nil时在预留下载次数之后、打开文件之前获取
+func (c *FileController) serveDownload(ctx *gin.Context, file *fileservice.FileMetadata, hook *downloadHook) {
+	id := file.ID
+
 	// 检查文件是否存在
-	if _, err := os.Stat(file.FilePath); os.IsNotExist(err) {
+	if _, err := c.fileService.StatFile(file); err == storage.ErrNotFound {
 		// 文件不存在，清理元数据
 		logger.Warnf("File not found on disk, cleaning metadata: %s", id)
-		c.fileService.DeleteFile(id)
+		if c.fileService.DeleteFile(id) == nil {
+			c.hub.Publish(file.Owner, events.TypeFileDelete, gin.H{"id": id})
+		}
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"code":    errors.ErrCodeFileDeleted,
 			"message": "文件已被删除",
@@ -277,38 +391,189 @@ func (c *FileController) DownloadFile(ctx *gin.Context) {
 		return
 	}
 
-	// 更新下载次数
-	_, err = c.fileService.UpdateFileMetadata(id, map[string]interface{}{
-		"downloadCount": file.DownloadCount + 1,
-	})
-	if err != nil {
-		logger.Errorf("Failed to update download count: %v", err)
+	// 校验任务发现内容已损坏时拒绝下载
+	if file.Corrupt {
+		logger.Warnf("Refusing to serve corrupted file: %s", id)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"code":    errors.ErrCodeUpdateDownloadCountFailed,
-			"message": "更新下载次数失败",
+			"code":    errors.ErrCodeFileCorrupted,
+			"message": "文件内容已损坏",
 		})
 		return
 	}
 
+	// 缓存校验
+	etag := fileETag(file)
+	lastModified := fileLastModified(file)
+	ctx.Header("ETag", etag)
+	ctx.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	ctx.Header("Accept-Ranges", "bytes")
+	// Content-Type来自内容检测，禁止浏览器再次猜测类型
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	if digest := digestHeader(file); digest != "" {
+		ctx.Header("Digest", digest)
+	}
+
+	if checkNotModified(ctx.Request, etag, lastModified) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	// 解析请求范围，If-Range不匹配时返回完整内容
+	var ranges []httpRange
+	if checkIfRange(ctx.Request, etag, lastModified) {
+		var err error
+		ranges, err = parseRange(ctx.GetHeader("Range"), file.Size)
+		if err != nil {
+			ctx.Header("Content-Range", fmt.Sprintf("bytes */%d", file.Size))
+			ctx.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{
+				"code":    errors.ErrCodeRangeNotSatisfiable,
+				"message": "请求的下载范围无效",
+			})
+			return
+		}
+	}
+
+	// 只有单个范围请求可能是中断下载的续传，由文件服务根据记录的中断位置判断是否计数
+	var offset int64
+	if len(ranges) == 1 {
+		offset = ranges[0].start
+	}
+	reservation, err := c.fileService.ReserveDownload(id, rateLimitKey(ctx), offset)
+	if err != nil {
+		switch err {
+		case fileservice.ErrMaxDownloadsReached:
+			logger.Warnf("File download limit reached: %s, max: %d", id, file.MaxDownloads)
+			ctx.JSON(http.StatusForbidden, gin.H{
+				"code":    errors.ErrCodeDownloadLimitReached,
+				"message": "文件下载次数已达上限",
+			})
+		case fileservice.ErrFileNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"code":    errors.ErrCodeFileNotFound,
+				"message": "文件不存在",
+			})
+		default:
+			logger.Errorf("Failed to update download count: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    errors.ErrCodeUpdateDownloadCountFailed,
+				"message": "更新下载次数失败",
+			})
+		}
+		return
+	}
+	if hook != nil && !hook.acquire(reservation.Resumed) {
+		if _, err := c.fileService.FinishDownload(reservation, false, true); err != nil {
+			logger.Errorf("Failed to refund download of %s: %v", id, err)
+		}
+		return
+	}
+
 	// 设置响应头
-	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", file.Filename))
-	ctx.Header("Content-Type", file.Mimetype)
-	ctx.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	ctx.Header("Content-Disposition", contentDisposition("attachment", file.Filename))
+
+	switch len(ranges) {
+	case 0:
+		err = c.serveFullFile(ctx, file)
+	case 1:
+		err = c.serveSingleRange(ctx, file, ranges[0])
+	default:
+		err = c.serveMultiRange(ctx, file, ranges)
+	}
+	// 多范围响应不记录续传位置
+	if len(ranges) <= 1 && ctx.Writer.Size() > 0 {
+		reservation.Written = int64(ctx.Writer.Size())
+	}
+
+	c.finishDownload(reservation, hook, err)
+}
 
+// finishDownload 结束下载预留：打开文件失败时退还下载次数和hook占用的次数，传输中断时按配置决定是否退还；
+// 最后一次允许的下载完成后文件会被自动删除
+func (c *FileController) finishDownload(reservation *fileservice.DownloadReservation, hook *downloadHook, err error) {
+	refund := err == errOpenFile || (err != nil && c.config.RefundAborted)
+	if refund && hook != nil {
+		hook.release()
+	}
+	deleted, err := c.fileService.FinishDownload(reservation, err == nil, refund)
+	if err != nil {
+		logger.Errorf("Failed to finish download of %s: %v", reservation.File.ID, err)
+	}
+	if deleted {
+		logger.Infof("File reached its download limit and was deleted: %s", reservation.File.ID)
+		c.hub.Publish(reservation.File.Owner, events.TypeFileDelete, gin.H{"id": reservation.File.ID})
+	}
+}
+
+// serveFullFile 返回完整文件内容
+func (c *FileController) serveFullFile(ctx *gin.Context, file *fileservice.FileMetadata) error {
 	// 打开文件
-	src, err := os.Open(file.FilePath)
+	src, err := c.fileService.OpenFile(file)
 	if err != nil {
 		logger.Errorf("Failed to open file for download: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    errors.ErrCodeOpenFileFailed,
 			"message": "打开文件失败",
 		})
-		return
+		return errOpenFile
 	}
 	defer src.Close()
 
-	// 实现速度限制的文件传输
-	c.speedLimitedCopy(ctx.Writer, src, file.Size, c.config.SpeedLimit)
+	ctx.Header("Content-Type", file.Mimetype)
+	ctx.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	ctx.Status(http.StatusOK)
+
+	// 按全局和客户端带宽限速传输
+	return c.limitedCopy(ctx, ctx.Writer, src)
+}
+
+// serveSingleRange 返回单个范围的文件内容
+func (c *FileController) serveSingleRange(ctx *gin.Context, file *fileservice.FileMetadata, r httpRange) error {
+	src, err := c.fileService.OpenFileRange(file, r.start, r.length)
+	if err != nil {
+		logger.Errorf("Failed to open file range for download: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeOpenFileFailed,
+			"message": "打开文件失败",
+		})
+		return errOpenFile
+	}
+	defer src.Close()
+
+	ctx.Header("Content-Type", file.Mimetype)
+	ctx.Header("Content-Range", r.contentRange(file.Size))
+	ctx.Header("Content-Length", strconv.FormatInt(r.length, 10))
+	ctx.Status(http.StatusPartialContent)
+
+	return c.limitedCopy(ctx, ctx.Writer, src)
+}
+
+// serveMultiRange 以multipart/byteranges格式返回多个范围的文件内容
+func (c *FileController) serveMultiRange(ctx *gin.Context, file *fileservice.FileMetadata, ranges []httpRange) error {
+	mw := multipart.NewWriter(ctx.Writer)
+	ctx.Header("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	ctx.Status(http.StatusPartialContent)
+
+	for _, r := range ranges {
+		src, err := c.fileService.OpenFileRange(file, r.start, r.length)
+		if err != nil {
+			// 响应头已发送，只能中断传输
+			logger.Errorf("Failed to open file range for download: %v", err)
+			return err
+		}
+
+		part, err := mw.CreatePart(r.mimeHeader(file.Mimetype, file.Size))
+		if err != nil {
+			src.Close()
+			return err
+		}
+		err = c.limitedCopy(ctx, part, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
 }
 
 // DeleteFile 删除文件
@@ -324,7 +589,12 @@ func (c *FileController) DownloadFile(ctx *gin.Context) {
 func (c *FileController) DeleteFile(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	if err := c.fileService.DeleteFile(id); err != nil {
+	// 只能删除当前命名空间中的文件
+	_, err := c.fileService.GetFileMetadata(currentNamespace(ctx), id)
+	if err == nil {
+		err = c.fileService.DeleteFile(id)
+	}
+	if err != nil {
 		if err == fileservice.ErrFileNotFound {
 			ctx.JSON(http.StatusNotFound, gin.H{
 				"code":    40401,
@@ -340,6 +610,9 @@ func (c *FileController) DeleteFile(ctx *gin.Context) {
 		return
 	}
 
+	logger.Infof("File %s deleted by %s", id, CurrentIdentity(ctx))
+	c.hub.Publish(currentNamespace(ctx), events.TypeFileDelete, gin.H{"id": id})
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "文件删除成功",
 	})
@@ -347,19 +620,25 @@ func (c *FileController) DeleteFile(ctx *gin.Context) {
 
 // GetFileThumbnail 获取文件缩略图
 // @Summary 获取文件缩略图
-// @Description 根据ID获取文件缩略图，仅支持图片文件
+// @Description 根据ID获取文件缩略图，支持JPEG/PNG/GIF/WebP图片，按EXIF方向旋转后等比缩放，
+// @Description 首次访问时生成并缓存，支持ETag条件请求，不计入下载次数
 // @Tags files
-// @Produce octet-stream
+// @Produce image/jpeg,image/png
 // @Param id path string true "文件ID"
+// @Param X-File-Password header string false "访问密码"
 // @Success 200 {file} file
+// @Success 304
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/files/{id}/thumbnail [get]
 func (c *FileController) GetFileThumbnail(ctx *gin.Context) {
 	id := ctx.Param("id")
 
 	// 获取文件元数据
-	file, err := c.fileService.GetFileMetadata(id)
+	file, err := c.fileService.GetFileMetadata(currentNamespace(ctx), id)
 	if err != nil {
 		if err == fileservice.ErrFileNotFound {
 			ctx.JSON(http.StatusNotFound, gin.H{
@@ -376,11 +655,27 @@ func (c *FileController) GetFileThumbnail(ctx *gin.Context) {
 		return
 	}
 
+	// 检查有效期和访问密码
+	if !c.checkFileAccess(ctx, file) {
+		return
+	}
+
+	// 不从已损坏的内容生成缩略图
+	if file.Corrupt {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeFileCorrupted,
+			"message": "文件内容已损坏",
+		})
+		return
+	}
+
 	// 检查文件是否存在
-	if _, err := os.Stat(file.FilePath); os.IsNotExist(err) {
+	if _, err := c.fileService.StatFile(file); err == storage.ErrNotFound {
 		// 文件不存在，清理元数据
 		logger.Warnf("File not found on disk, cleaning metadata: %s", id)
-		c.fileService.DeleteFile(id)
+		if c.fileService.DeleteFile(id) == nil {
+			c.hub.Publish(currentNamespace(ctx), events.TypeFileDelete, gin.H{"id": id})
+		}
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"code":    errors.ErrCodeFileDeleted,
 			"message": "文件已被删除",
@@ -388,74 +683,211 @@ func (c *FileController) GetFileThumbnail(ctx *gin.Context) {
 		return
 	}
 
-	// 检查文件是否为图片
-	isImage := false
-	imageExtensions := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
+	// 检查文件是否为支持的图片类型
+	if !thumbnail.Supported(file.Mimetype, strings.ToLower(filepath.Ext(file.Filename))) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidFileFormat,
+			"message": "该文件类型不支持缩略图",
+		})
+		return
 	}
-	fileExt := filepath.Ext(file.FilePath)
-	if imageExtensions[fileExt] {
-		isImage = true
+
+	// 文件内容不会改变，缩略图可以长期缓存
+	etag := fmt.Sprintf(`"thumb-%d-%s"`, c.fileService.ThumbnailSize(), strings.Trim(fileETag(file), `"`))
+	lastModified := fileLastModified(file)
+	ctx.Header("ETag", etag)
+	ctx.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	ctx.Header("Cache-Control", "private, max-age=604800")
+
+	if checkNotModified(ctx.Request, etag, lastModified) {
+		ctx.Status(http.StatusNotModified)
+		return
 	}
 
-	if !isImage {
+	data, contentType, err := c.fileService.GetThumbnail(file)
+	if err == thumbnail.ErrUnsupportedImage || err == thumbnail.ErrImageTooLarge {
+		ctx.Header("Cache-Control", "no-store")
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"code":    errors.ErrCodeInvalidFileFormat,
 			"message": "该文件类型不支持缩略图",
 		})
 		return
 	}
+	if err != nil {
+		logger.Errorf("Failed to generate thumbnail: %v", err)
+		ctx.Header("Cache-Control", "no-store")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeThumbnailFailed,
+			"message": "生成缩略图失败",
+		})
+		return
+	}
 
-	// 设置响应头
-	ctx.Header("Content-Type", file.Mimetype)
-	ctx.Header("Content-Length", strconv.FormatInt(file.Size, 10))
-
-	// 返回文件内容
-	ctx.File(file.FilePath)
+	ctx.Data(http.StatusOK, contentType, data)
 }
 
-// speedLimitedCopy 带速度限制的文件复制
-func (c *FileController) speedLimitedCopy(dst io.Writer, src io.Reader, size, speedLimit int64) {
-	buffer := make([]byte, 64*1024) // 64KB缓冲区
-	var totalWritten int64
-	startTime := time.Now()
-
-	for {
-		// 计算剩余时间和剩余数据
-		elapsed := time.Since(startTime).Milliseconds()
-		expectedTime := (totalWritten * 1000) / speedLimit
-		if elapsed < expectedTime {
-			// 需要延迟
-			time.Sleep(time.Duration(expectedTime-elapsed) * time.Millisecond)
-		}
+// PreviewFile 预览文件
+// @Summary 预览文件
+// @Description 根据ID在浏览器中内联预览文件，不计入下载次数：图片返回长边不超过previewSize的缩小版本（原图需要下载）；代码和文本返回语法高亮的HTML；
+// @Description Markdown渲染后经过白名单过滤；日志等文本只返回前previewMaxSize字节
+// @Tags files
+// @Produce text/html,image/jpeg,image/png
+// @Param id path string true "文件ID"
+// @Param X-File-Password header string false "访问密码"
+// @Success 200 {file} file
+// @Success 304
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/files/{id}/preview [get]
+func (c *FileController) PreviewFile(ctx *gin.Context) {
+	id := ctx.Param("id")
 
-		// 计算本次可以读取的数据量
-		remaining := speedLimit - (totalWritten % speedLimit)
-		if remaining > int64(len(buffer)) {
-			remaining = int64(len(buffer))
+	// 获取文件元数据
+	file, err := c.fileService.GetFileMetadata(currentNamespace(ctx), id)
+	if err != nil {
+		if err == fileservice.ErrFileNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"code":    errors.ErrCodeFileNotFound,
+				"message": "文件不存在",
+			})
+			return
 		}
+		logger.Errorf("Failed to get file metadata for preview: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeGetFileMetaFailed,
+			"message": "获取文件信息失败",
+		})
+		return
+	}
 
-		// 读取数据
-		n, err := src.Read(buffer[:remaining])
-		if err != nil {
-			if err != io.EOF {
-				fmt.Printf("Error reading file: %v\n", err)
-			}
-			break
-		}
+	// 检查有效期和访问密码
+	if !c.checkFileAccess(ctx, file) {
+		return
+	}
 
-		// 写入数据
-		n, err = dst.Write(buffer[:n])
-		if err != nil {
-			fmt.Printf("Error writing file: %v\n", err)
-			break
-		}
+	kind := preview.Kind(file.Mimetype, file.Filename)
+	if kind == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidFileFormat,
+			"message": "该文件类型不支持预览",
+		})
+		return
+	}
+
+	if file.Corrupt {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeFileCorrupted,
+			"message": "文件内容已损坏",
+		})
+		return
+	}
 
-		totalWritten += int64(n)
-		if totalWritten >= size {
-			break
+	// 检查文件是否存在
+	if _, err := c.fileService.StatFile(file); err == storage.ErrNotFound {
+		// 文件不存在，清理元数据
+		logger.Warnf("File not found on disk, cleaning metadata: %s", id)
+		if c.fileService.DeleteFile(id) == nil {
+			c.hub.Publish(currentNamespace(ctx), events.TypeFileDelete, gin.H{"id": id})
 		}
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    errors.ErrCodeFileDeleted,
+			"message": "文件已被删除",
+		})
+		return
 	}
+
+	// 图片预览的尺寸可配置，修改后缓存失效
+	etag := fmt.Sprintf(`"preview-%d-%s"`, c.fileService.PreviewSize(), strings.Trim(fileETag(file), `"`))
+	lastModified := fileLastModified(file)
+	ctx.Header("ETag", etag)
+	ctx.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	ctx.Header("Cache-Control", "private, max-age=3600")
+	ctx.Header("X-Content-Type-Options", "nosniff")
+
+	if checkNotModified(ctx.Request, etag, lastModified) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	if kind == preview.KindImage {
+		c.previewImage(ctx, file)
+		return
+	}
+
+	// 只读取预览需要的部分，多读1字节用于判断是否截断
+	src, err := c.fileService.OpenFileRange(file, 0, c.config.PreviewMaxSize+1)
+	if err != nil {
+		logger.Errorf("Failed to open file for preview: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeOpenFileFailed,
+			"message": "打开文件失败",
+		})
+		return
+	}
+	defer src.Close()
+
+	page, err := preview.Render(src, kind, file.Filename, c.config.PreviewMaxSize)
+	if err == preview.ErrUnsupported {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidFileFormat,
+			"message": "该文件类型不支持预览",
+		})
+		return
+	}
+	if err != nil {
+		logger.Errorf("Failed to render preview: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodePreviewFailed,
+			"message": "生成预览失败",
+		})
+		return
+	}
+
+	// 预览页面不允许执行脚本和加载外部资源
+	ctx.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:; sandbox")
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// previewImage 内联返回缩小后的图片，预览不计入下载次数，原图只能通过下载接口获取
+func (c *FileController) previewImage(ctx *gin.Context, file *fileservice.FileMetadata) {
+	data, contentType, err := c.fileService.GetPreviewImage(file)
+	if err == thumbnail.ErrUnsupportedImage || err == thumbnail.ErrImageTooLarge {
+		ctx.Header("Cache-Control", "no-store")
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidFileFormat,
+			"message": "该文件类型不支持预览",
+		})
+		return
+	}
+	if err != nil {
+		logger.Errorf("Failed to generate preview image: %v", err)
+		ctx.Header("Cache-Control", "no-store")
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodePreviewFailed,
+			"message": "生成预览失败",
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", contentDisposition("inline", file.Filename))
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// limitedCopy 按下载限速器复制文件内容，客户端断开时停止
+func (c *FileController) limitedCopy(ctx *gin.Context, dst io.Writer, src io.Reader) error {
+	_, err := io.Copy(c.downloadLimiter.Writer(ctx.Request.Context(), dst, rateLimitKey(ctx)), src)
+	if err != nil && ctx.Request.Context().Err() == nil {
+		logger.Errorf("Failed to copy file content: %v", err)
+	}
+
+	return err
+}
+
+// contentDisposition 生成Content-Disposition响应头，文件名按需加引号或使用RFC 2231编码，
+// 避免文件名中的分号、引号或换行改变响应头的含义
+func contentDisposition(disposition, filename string) string {
+	return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
 }
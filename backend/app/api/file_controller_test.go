@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/events"
+	fileservice "cloud-clipboard/internal/file"
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/ratelimit"
+	"cloud-clipboard/internal/storage"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+	gin.SetMode(gin.TestMode)
+
+	os.Exit(m.Run())
+}
+
+// newTestFileController 创建使用临时目录的文件控制器和只注册了下载、预览和缩略图接口的路由
+func newTestFileController(t *testing.T) (*FileController, *gin.Engine) {
+	t.Helper()
+
+	return newTestFileControllerIn(t, t.TempDir())
+}
+
+// newTestFileControllerIn 与newTestFileController相同，文件内容保存在dir下的uploads目录
+func newTestFileControllerIn(t *testing.T, dir string) (*FileController, *gin.Engine) {
+	t.Helper()
+
+	blobStorage, err := storage.NewLocalStorage(filepath.Join(dir, "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	metadataStore, err := fileservice.NewJSONMetadataStore(filepath.Join(dir, "metadata.json"), 0)
+	if err != nil {
+		t.Fatalf("NewJSONMetadataStore: %v", err)
+	}
+	fileService, err := fileservice.NewFileService(blobStorage, metadataStore, 32, 64, fileservice.TypePolicy{})
+	if err != nil {
+		t.Fatalf("NewFileService: %v", err)
+	}
+
+	c := NewFileController(fileService, events.NewHub(0), ratelimit.New(ratelimit.Config{}), &config.FileConfig{
+		MaxAge: int64(time.Hour / time.Millisecond),
+	})
+
+	r := gin.New()
+	r.GET("/api/files/:id/download", c.DownloadFile)
+	r.GET("/api/files/:id/preview", c.PreviewFile)
+	r.GET("/api/files/:id/thumbnail", c.GetFileThumbnail)
+
+	return c, r
+}
+
+func TestDownloadRangeCannotBypassMaxDownloads(t *testing.T) {
+	const maxDownloads = 3
+
+	for _, header := range []string{"", "bytes=1-", "bytes=1-,0-0", "bytes=-9", "bytes=5-5"} {
+		t.Run(header, func(t *testing.T) {
+			c, r := newTestFileController(t)
+			file, err := c.fileService.AddFile(strings.NewReader("0123456789"), &fileservice.FileInfo{
+				OriginalName: "test.txt",
+				Size:         10,
+				SharePolicy:  fileservice.SharePolicy{MaxDownloads: maxDownloads},
+			})
+			if err != nil {
+				t.Fatalf("AddFile: %v", err)
+			}
+
+			served := 0
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/api/files/"+file.ID+"/download", nil)
+				if header != "" {
+					req.Header.Set("Range", header)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				switch w.Code {
+				case http.StatusOK, http.StatusPartialContent:
+					served++
+				case http.StatusForbidden, http.StatusNotFound:
+				default:
+					t.Fatalf("request %d: unexpected status %d: %s", i, w.Code, w.Body.String())
+				}
+			}
+
+			if served != maxDownloads {
+				t.Fatalf("served %d downloads, want %d", served, maxDownloads)
+			}
+		})
+	}
+}
+
+func TestPreviewImageIsScaledAndNotCounted(t *testing.T) {
+	c, r := newTestFileController(t)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	file, err := c.fileService.AddFile(bytes.NewReader(buf.Bytes()), &fileservice.FileInfo{
+		OriginalName: "image.png",
+		Size:         int64(buf.Len()),
+		SharePolicy:  fileservice.SharePolicy{MaxDownloads: 1},
+	})
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/files/"+file.ID+"/preview", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("preview %d: status = %d: %s", i, w.Code, w.Body.String())
+		}
+
+		cfg, _, err := image.DecodeConfig(w.Body)
+		if err != nil {
+			t.Fatalf("preview %d: DecodeConfig: %v", i, err)
+		}
+		if cfg.Width != 64 || cfg.Height != 32 {
+			t.Fatalf("preview %d: size = %dx%d, want 64x32", i, cfg.Width, cfg.Height)
+		}
+	}
+
+	metadata, err := c.fileService.GetFileMetadata("", file.ID)
+	if err != nil {
+		t.Fatalf("GetFileMetadata: %v", err)
+	}
+	if metadata.DownloadCount != 0 {
+		t.Fatalf("download count = %d, want 0", metadata.DownloadCount)
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"report.pdf", "attachment; filename=report.pdf"},
+		{"my report.pdf", `attachment; filename="my report.pdf"`},
+		{`a"b;c.txt`, `attachment; filename="a\"b;c.txt"`},
+		{"报告.pdf", "attachment; filename*=utf-8''%E6%8A%A5%E5%91%8A.pdf"},
+		{"a\r\nSet-Cookie: x=1", "attachment; filename*=utf-8''a%0D%0ASet-Cookie%3A%20x%3D1"},
+	}
+
+	for _, tt := range tests {
+		got := contentDisposition("attachment", tt.filename)
+		if got != tt.want {
+			t.Errorf("contentDisposition(%q) = %q, want %q", tt.filename, got, tt.want)
+			continue
+		}
+		_, params, err := mime.ParseMediaType(got)
+		if err != nil || params["filename"] != tt.filename {
+			t.Errorf("ParseMediaType(%q) = %q, %v, want %q", got, params["filename"], err, tt.filename)
+		}
+	}
+}
+
+func TestCorruptFileIsNotServed(t *testing.T) {
+	dir := t.TempDir()
+	c, r := newTestFileControllerIn(t, dir)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	file, err := c.fileService.AddFile(bytes.NewReader(buf.Bytes()), &fileservice.FileInfo{
+		OriginalName: "image.png",
+		Size:         int64(buf.Len()),
+	})
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	// 存储中的内容损坏后被校验任务标记
+	if err := os.WriteFile(filepath.Join(dir, "uploads", file.BlobKey()), []byte("corrupt"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if result, err := c.fileService.ScrubFiles(); err != nil || len(result.Corrupted) != 1 {
+		t.Fatalf("ScrubFiles = %+v, %v", result, err)
+	}
+
+	for _, path := range []string{"download", "preview", "thumbnail"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/files/"+file.ID+"/"+path, nil))
+		if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"code":50013`) {
+			t.Errorf("%s: status = %d: %s, want 500 with code 50013", path, w.Code, w.Body.String())
+		}
+	}
+}
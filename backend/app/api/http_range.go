@@ -0,0 +1,178 @@
+package api
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	fileservice "cloud-clipboard/internal/file"
+)
+
+// errRangeNotSatisfiable Range请求头中没有可满足的范围
+var errRangeNotSatisfiable = stderrors.New("range not satisfiable")
+
+// httpRange 请求的字节范围
+type httpRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange 该范围对应的Content-Range响应头
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// mimeHeader 多范围响应中该范围的分段头
+func (r httpRange) mimeHeader(contentType string, size int64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Range": {r.contentRange(size)},
+		"Content-Type":  {contentType},
+	}
+}
+
+// fileETag 根据文件元数据生成ETag，有摘要时直接使用内容摘要；
+// 旧版本没有摘要的文件内容上传后不会改变，ID、大小和上传时间即可唯一标识内容
+func fileETag(file *fileservice.FileMetadata) string {
+	if file.Digest != "" {
+		return fmt.Sprintf(`"sha256-%s"`, file.Digest)
+	}
+	return fmt.Sprintf(`"%s-%x-%x"`, file.ID, file.Size, file.UploadTime)
+}
+
+// fileLastModified 文件的最后修改时间，即上传时间
+func fileLastModified(file *fileservice.FileMetadata) time.Time {
+	return time.UnixMilli(file.UploadTime).UTC().Truncate(time.Second)
+}
+
+// checkNotModified 根据If-None-Match和If-Modified-Since判断客户端缓存是否仍然有效
+func checkNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, etag, true)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.After(t)
+	}
+
+	return false
+}
+
+// checkIfRange 判断If-Range条件是否满足，不满足时应忽略Range返回完整内容
+func checkIfRange(r *http.Request, etag string, lastModified time.Time) bool {
+	ir := r.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+
+	// If-Range中的ETag必须使用强比较
+	if strings.HasPrefix(ir, `"`) || strings.HasPrefix(ir, "W/") {
+		return etagListMatches(ir, etag, false)
+	}
+
+	t, err := http.ParseTime(ir)
+	return err == nil && lastModified.Equal(t)
+}
+
+// etagListMatches 判断逗号分隔的ETag列表中是否包含etag，weak为true时使用弱比较
+func etagListMatches(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" && weak {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRange 解析Range请求头，header为空或范围总长度超过文件大小时返回nil表示返回完整内容
+func parseRange(header string, size int64) ([]httpRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		// 不支持的范围单位，按规范忽略Range
+		return nil, nil
+	}
+
+	var ranges []httpRange
+	var total int64
+	for _, spec := range strings.Split(header[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		startStr, endStr, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, errRangeNotSatisfiable
+		}
+		startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+
+		var r httpRange
+		if startStr == "" {
+			// 后缀范围：最后N个字节
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errRangeNotSatisfiable
+			}
+			if n == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r = httpRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errRangeNotSatisfiable
+			}
+			if start >= size {
+				// 起始位置超出文件大小的范围不可满足，跳过
+				continue
+			}
+
+			end := size - 1
+			if endStr != "" {
+				end, err = strconv.ParseInt(endStr, 10, 64)
+				if err != nil || end < start {
+					return nil, errRangeNotSatisfiable
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			r = httpRange{start: start, length: end - start + 1}
+		}
+
+		ranges = append(ranges, r)
+		total += r.length
+	}
+
+	if len(ranges) == 0 {
+		return nil, errRangeNotSatisfiable
+	}
+
+	// 范围总长度超过文件大小时（例如大量重叠范围），直接返回完整内容
+	if total > size {
+		return nil, nil
+	}
+
+	return ranges, nil
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header  string
+		size    int64
+		want    []httpRange
+		wantErr bool
+	}{
+		{header: "", size: 10},
+		{header: "items=0-1", size: 10},
+		{header: "bytes=0-", size: 10, want: []httpRange{{0, 10}}},
+		{header: "bytes=1-", size: 10, want: []httpRange{{1, 9}}},
+		{header: "bytes=2-5", size: 10, want: []httpRange{{2, 4}}},
+		{header: "bytes=5-100", size: 10, want: []httpRange{{5, 5}}},
+		{header: "bytes=-3", size: 10, want: []httpRange{{7, 3}}},
+		{header: "bytes=-20", size: 10, want: []httpRange{{0, 10}}},
+		{header: "bytes=0-0, 5-6", size: 10, want: []httpRange{{0, 1}, {5, 2}}},
+		{header: "bytes=1-,0-0", size: 10, want: []httpRange{{1, 9}, {0, 1}}},
+		{header: "bytes=0-4,3-9", size: 10},
+		{header: "bytes=20-, 1-1", size: 10, want: []httpRange{{1, 1}}},
+		{header: "bytes=10-", size: 10, wantErr: true},
+		{header: "bytes=-0", size: 10, wantErr: true},
+		{header: "bytes=5-2", size: 10, wantErr: true},
+		{header: "bytes=a-b", size: 10, wantErr: true},
+		{header: "bytes=-1-", size: 10, wantErr: true},
+		{header: "bytes=5", size: 10, wantErr: true},
+		{header: "bytes=", size: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRange(tt.header, tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRange(%q, %d) err = %v, wantErr %v", tt.header, tt.size, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRange(%q, %d) = %v, want %v", tt.header, tt.size, got, tt.want)
+		}
+	}
+}
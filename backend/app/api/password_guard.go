@@ -0,0 +1,135 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"sync"
+	"time"
+)
+
+// 访问密码校验的限制
+const (
+	// passwordAttemptWindow 失败次数的统计窗口
+	passwordAttemptWindow = time.Minute
+	// clientPasswordAttempts 单个客户端每分钟允许的密码错误次数（所有文件和链接合计）
+	clientPasswordAttempts = 10
+	// targetPasswordAttempts 单个文件或链接每分钟不受延迟的密码错误次数，不依赖客户端标识
+	targetPasswordAttempts = 30
+	// targetPasswordDelay 超过targetPasswordAttempts后每次校验前的延迟，
+	// 只拖慢穷举而不拒绝请求，其他客户端的失败不会使正确的密码被拒绝
+	targetPasswordDelay = 2 * time.Second
+	// passwordCacheTTL 校验成功的结果缓存时间，覆盖一次下载的续传和分段请求
+	passwordCacheTTL = 10 * time.Minute
+)
+
+// 密码校验错误
+var (
+	errPasswordIncorrect    = stderrors.New("incorrect password")
+	errTooManyPasswordTries = stderrors.New("too many password attempts")
+)
+
+// passwordFailures 一个统计窗口内的失败次数
+type passwordFailures struct {
+	count   int
+	resetAt time.Time
+}
+
+// passwordGuard 访问密码校验：按客户端限制失败次数，按目标在失败过多时延迟校验，避免穷举密码和大量bcrypt计算；
+// 校验成功的结果按客户端、目标和密码缓存，同一下载的续传和分段请求不再重复计算bcrypt
+type passwordGuard struct {
+	failures map[string]*passwordFailures
+	verified map[string]time.Time
+	delay    time.Duration
+	mu       sync.Mutex
+}
+
+// newPasswordGuard 创建访问密码校验
+func newPasswordGuard() *passwordGuard {
+	return &passwordGuard{
+		failures: make(map[string]*passwordFailures),
+		verified: make(map[string]time.Time),
+		delay:    targetPasswordDelay,
+	}
+}
+
+// verify 校验client对target（文件或链接）提供的password，hash为target当前的密码哈希，compare执行实际的bcrypt比较；
+// 尝试在比较之前就计入失败次数，校验成功后退还，并发的请求无法超出限制；
+// client失败次数过多时返回errTooManyPasswordTries，target失败次数过多时延迟后仍然校验，密码错误时返回errPasswordIncorrect
+func (g *passwordGuard) verify(client, target, hash, password string, compare func(password string) bool) error {
+	clientKey, targetKey := "client:"+client, "target:"+target
+	// 缓存键包含密码哈希，修改密码后缓存自动失效
+	sum := sha256.Sum256([]byte(client + "\x00" + target + "\x00" + hash + "\x00" + password))
+	cacheKey := hex.EncodeToString(sum[:])
+
+	g.mu.Lock()
+	now := time.Now()
+	g.prune(now)
+	if expiresAt, ok := g.verified[cacheKey]; ok && now.Before(expiresAt) {
+		g.mu.Unlock()
+		return nil
+	}
+	if g.exceeded(clientKey, clientPasswordAttempts) {
+		g.mu.Unlock()
+		return errTooManyPasswordTries
+	}
+	throttled := g.exceeded(targetKey, targetPasswordAttempts)
+	g.fail(clientKey, now)
+	g.fail(targetKey, now)
+	g.mu.Unlock()
+
+	if throttled {
+		time.Sleep(g.delay)
+	}
+	// bcrypt比较较慢，不持有锁
+	ok := compare(password)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !ok {
+		return errPasswordIncorrect
+	}
+	g.refund(clientKey)
+	g.refund(targetKey)
+	g.verified[cacheKey] = time.Now().Add(passwordCacheTTL)
+
+	return nil
+}
+
+// exceeded key的失败次数是否已达到limit，调用方需持有锁
+func (g *passwordGuard) exceeded(key string, limit int) bool {
+	f := g.failures[key]
+	return f != nil && f.count >= limit
+}
+
+// fail 记录key的一次失败，调用方需持有锁
+func (g *passwordGuard) fail(key string, now time.Time) {
+	f := g.failures[key]
+	if f == nil {
+		f = &passwordFailures{resetAt: now.Add(passwordAttemptWindow)}
+		g.failures[key] = f
+	}
+	f.count++
+}
+
+// refund 退还key的一次失败，用于校验成功的尝试，调用方需持有锁
+func (g *passwordGuard) refund(key string) {
+	if f := g.failures[key]; f != nil && f.count > 0 {
+		f.count--
+	}
+}
+
+// prune 清理已过期的失败记录和缓存，调用方需持有锁
+func (g *passwordGuard) prune(now time.Time) {
+	for key, f := range g.failures {
+		if now.After(f.resetAt) {
+			delete(g.failures, key)
+		}
+	}
+	for key, expiresAt := range g.verified {
+		if !now.Before(expiresAt) {
+			delete(g.verified, key)
+		}
+	}
+}
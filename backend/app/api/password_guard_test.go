@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fileservice "cloud-clipboard/internal/file"
+)
+
+func TestPasswordGuard(t *testing.T) {
+	g := newPasswordGuard()
+	compares := 0
+	compare := func(password string) bool {
+		compares++
+		return password == "secret"
+	}
+
+	// 校验成功的结果被缓存
+	for i := 0; i < 3; i++ {
+		if err := g.verify("ip:1", "file:a", "hash-1", "secret", compare); err != nil {
+			t.Fatalf("verify correct password: %v", err)
+		}
+	}
+	if compares != 1 {
+		t.Fatalf("compare called %d times, want 1", compares)
+	}
+	// 修改密码后缓存失效
+	if err := g.verify("ip:1", "file:a", "hash-2", "secret", compare); err != nil || compares != 2 {
+		t.Fatalf("verify after password change: err = %v, compares = %d", err, compares)
+	}
+
+	// 单个客户端的失败次数限制
+	for i := 0; i < clientPasswordAttempts; i++ {
+		if err := g.verify("ip:2", "file:a", "hash-1", "wrong", compare); err != errPasswordIncorrect {
+			t.Fatalf("attempt %d: err = %v, want errPasswordIncorrect", i, err)
+		}
+	}
+	before := compares
+	if err := g.verify("ip:2", "file:b", "hash-1", "secret", compare); err != errTooManyPasswordTries {
+		t.Fatalf("client over budget: err = %v, want errTooManyPasswordTries", err)
+	}
+	if compares != before {
+		t.Fatal("compare called for a throttled client")
+	}
+	// 已缓存的成功结果不受失败次数影响
+	if err := g.verify("ip:1", "file:a", "hash-2", "secret", compare); err != nil {
+		t.Fatalf("cached verify: %v", err)
+	}
+
+	// 单个目标的失败次数过多时延迟校验而不拒绝，其他客户端的失败不影响正确的密码
+	g.delay = 20 * time.Millisecond
+	for i := 0; i < targetPasswordAttempts; i++ {
+		client := "ip:spoofed-" + string(rune('a'+i))
+		if err := g.verify(client, "file:c", "hash-1", "wrong", compare); err != errPasswordIncorrect {
+			t.Fatalf("attempt %d: err = %v, want errPasswordIncorrect", i, err)
+		}
+	}
+	start := time.Now()
+	if err := g.verify("ip:3", "file:c", "hash-1", "secret", compare); err != nil {
+		t.Fatalf("correct password after other clients failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < g.delay {
+		t.Fatalf("target over budget verified after %v, want a delay of at least %v", elapsed, g.delay)
+	}
+	if err := g.verify("ip:4", "file:c", "hash-1", "wrong", compare); err != errPasswordIncorrect {
+		t.Fatalf("wrong password over target budget: err = %v, want errPasswordIncorrect", err)
+	}
+}
+
+func TestPasswordGuardCountsConcurrentAttempts(t *testing.T) {
+	g := newPasswordGuard()
+	var compares int32
+	release := make(chan struct{})
+	compare := func(password string) bool {
+		atomic.AddInt32(&compares, 1)
+		<-release
+		return false
+	}
+
+	// 所有请求在比较完成之前到达，仍然只有clientPasswordAttempts次会执行比较
+	var wg sync.WaitGroup
+	results := make(chan error, 2*clientPasswordAttempts)
+	for i := 0; i < 2*clientPasswordAttempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- g.verify("ip:1", "file:a", "hash-1", "wrong", compare)
+		}()
+	}
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&compares) < clientPasswordAttempts && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	throttled := 0
+	for err := range results {
+		if err == errTooManyPasswordTries {
+			throttled++
+		}
+	}
+	if compares != clientPasswordAttempts || throttled != clientPasswordAttempts {
+		t.Fatalf("compares = %d, throttled = %d, want %d each", compares, throttled, clientPasswordAttempts)
+	}
+}
+
+func TestDownloadPasswordOnlyFromHeader(t *testing.T) {
+	c, r := newTestFileController(t)
+	hash, err := fileservice.HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	file, err := c.fileService.AddFile(strings.NewReader("0123456789"), &fileservice.FileInfo{
+		OriginalName: "test.txt",
+		Size:         10,
+		SharePolicy:  fileservice.SharePolicy{PasswordHash: hash},
+	})
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+
+	tests := []struct {
+		query, header string
+		want          int
+	}{
+		{"?password=secret", "", http.StatusUnauthorized},
+		{"", "wrong", http.StatusUnauthorized},
+		{"", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/files/"+file.ID+"/download"+tt.query, nil)
+		if tt.header != "" {
+			req.Header.Set("X-File-Password", tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("query %q header %q: status = %d, want %d", tt.query, tt.header, w.Code, tt.want)
+		}
+	}
+}
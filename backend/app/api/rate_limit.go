@@ -0,0 +1,37 @@
+package api
+
+import (
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/internal/ratelimit"
+)
+
+// RateLimitUpload 上传限速中间件，按客户端对请求体限速
+func RateLimitUpload(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		body := ctx.Request.Body
+		ctx.Request.Body = &limitedBody{
+			Reader: limiter.Reader(ctx.Request.Context(), body, rateLimitKey(ctx)),
+			Closer: body,
+		}
+		ctx.Next()
+	}
+}
+
+// limitedBody 限速后的请求体
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// rateLimitKey 获取客户端的限速键，通过令牌认证的请求按令牌ID限速，匿名请求按IP限速；
+// 只有来自server.trustedProxies的请求才使用X-Forwarded-For中的地址，其余请求使用连接的对端地址
+func rateLimitKey(ctx *gin.Context) string {
+	if identity := CurrentIdentity(ctx); !identity.IsAnonymous() {
+		return "token:" + identity.TokenID
+	}
+
+	return "ip:" + ctx.ClientIP()
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitKeyIgnoresUntrustedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		remote  string
+		want    string
+	}{
+		{"no trusted proxies", nil, "203.0.113.5:1234", "ip:203.0.113.5"},
+		{"untrusted peer", []string{"127.0.0.1"}, "203.0.113.5:1234", "ip:203.0.113.5"},
+		{"trusted proxy", []string{"127.0.0.1"}, "127.0.0.1:1234", "ip:198.51.100.7"},
+		{"trusted network", []string{"10.0.0.0/8"}, "10.1.2.3:1234", "ip:198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			if err := r.SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatalf("SetTrustedProxies: %v", err)
+			}
+			var got string
+			r.GET("/", func(ctx *gin.Context) {
+				got = rateLimitKey(ctx)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+			req.Header.Set("X-Real-IP", "198.51.100.7")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Fatalf("rateLimitKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/auth"
+	"cloud-clipboard/internal/errors"
+	"cloud-clipboard/internal/events"
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/room"
+)
+
+// roomKey gin上下文中保存当前房间的键
+const roomKey = "room"
+
+// roomScopes 房间成员在房间内拥有的权限
+var roomScopes = []string{auth.ScopeClipboardRead, auth.ScopeClipboardWrite, auth.ScopeFilesRead, auth.ScopeFilesWrite}
+
+// JoinedRoom 房间中间件，请求携带X-Room-Token请求头（EventSource和下载链接可以使用room_token查询参数）时，
+// 请求身份替换为房间成员，之后的剪切板、文件和事件接口都作用于房间的命名空间
+func JoinedRoom(rooms *room.Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		secret := roomToken(ctx)
+		if secret == "" {
+			ctx.Next()
+			return
+		}
+
+		r, err := rooms.Authenticate(secret)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    errors.ErrCodeInvalidRoomToken,
+				"message": "房间不存在或已过期",
+			})
+			return
+		}
+
+		ctx.Set(roomKey, r)
+		ctx.Set(identityKey, &auth.Identity{
+			Name:      "room " + r.Code,
+			Scopes:    roomScopes,
+			Namespace: r.Namespace(),
+		})
+		ctx.Next()
+	}
+}
+
+// CurrentRoom 获取当前请求所在的房间，不在房间中时返回nil
+func CurrentRoom(ctx *gin.Context) *room.Room {
+	if v, ok := ctx.Get(roomKey); ok {
+		if r, ok := v.(*room.Room); ok {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// roomToken 获取请求携带的房间成员令牌
+func roomToken(ctx *gin.Context) string {
+	if token := ctx.GetHeader("X-Room-Token"); token != "" {
+		return token
+	}
+
+	return ctx.Query("room_token")
+}
+
+// RoomController 房间控制器
+type RoomController struct {
+	rooms  *room.Manager
+	hub    *events.Hub
+	server *config.ServerConfig
+}
+
+// NewRoomController 创建新的房间控制器，server用于生成joinUrl
+func NewRoomController(rooms *room.Manager, hub *events.Hub, server *config.ServerConfig) *RoomController {
+	return &RoomController{
+		rooms:  rooms,
+		hub:    hub,
+		server: server,
+	}
+}
+
+// CreateRoomRequest 创建房间请求
+type CreateRoomRequest struct {
+	// ExpiresIn 有效期（毫秒），为空或0表示使用默认有效期
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+// JoinRoomRequest 加入房间请求
+type JoinRoomRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// CreateRoom 创建房间
+// @Summary 创建房间
+// @Description 创建房间并返回8位配对码和成员令牌，其他设备输入配对码（或扫描joinUrl生成的二维码）即可加入。
+// @Description 之后的请求通过X-Room-Token请求头携带成员令牌，剪切板和文件接口都作用于房间；房间过期或所有设备离开后，房间内的内容被删除
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param room body CreateRoomRequest false "房间有效期"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /api/rooms [post]
+func (c *RoomController) CreateRoom(ctx *gin.Context) {
+	var req CreateRoomRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    errors.ErrCodeInvalidRoomRequest,
+				"message": "房间参数无效",
+			})
+			return
+		}
+	}
+
+	r, secret, err := c.rooms.Create(req.ExpiresIn)
+	if err != nil {
+		c.handleRoomError(ctx, err)
+		return
+	}
+
+	logger.Infof("Room %s created by %s, expires at %d", r.ID, CurrentIdentity(ctx), r.ExpiresAt)
+
+	response := c.roomResponse(ctx, r)
+	response["token"] = secret
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// JoinRoom 加入房间
+// @Summary 加入房间
+// @Description 使用8位配对码加入房间，返回成员令牌；每个客户端每分钟只允许有限次数的失败尝试，
+// @Description 每个配对码被所有客户端尝试失败一定次数后更换，房间成员可以通过GET /api/rooms/current获取新的配对码
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param room body JoinRoomRequest true "配对码"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 429 {object} map[string]interface{}
+// @Router /api/rooms/join [post]
+func (c *RoomController) JoinRoom(ctx *gin.Context) {
+	var req JoinRoomRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidRoomRequest,
+			"message": "配对码无效",
+		})
+		return
+	}
+
+	r, secret, err := c.rooms.Join(req.Code, ctx.ClientIP())
+	if err != nil {
+		c.handleRoomError(ctx, err)
+		return
+	}
+
+	logger.Infof("Device %s joined room %s, %d members", ctx.ClientIP(), r.ID, r.Members)
+	c.hub.Publish(r.Namespace(), events.TypeRoomJoin, gin.H{"members": r.Members})
+
+	response := c.roomResponse(ctx, r)
+	response["token"] = secret
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetRoom 获取当前房间
+// @Summary 获取当前房间
+// @Description 获取X-Room-Token所属房间的配对码、有效期和设备数
+// @Tags rooms
+// @Produce json
+// @Param X-Room-Token header string true "成员令牌"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/rooms/current [get]
+func (c *RoomController) GetRoom(ctx *gin.Context) {
+	r := CurrentRoom(ctx)
+	if r == nil {
+		notInRoom(ctx)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c.roomResponse(ctx, r))
+}
+
+// LeaveRoom 离开房间
+// @Summary 离开房间
+// @Description 当前设备离开房间，成员令牌立即失效；最后一个设备离开时关闭房间并删除房间内的剪切板和文件
+// @Tags rooms
+// @Produce json
+// @Param X-Room-Token header string true "成员令牌"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/rooms/current [delete]
+func (c *RoomController) LeaveRoom(ctx *gin.Context) {
+	if CurrentRoom(ctx) == nil {
+		notInRoom(ctx)
+		return
+	}
+
+	r, closed, err := c.rooms.Leave(roomToken(ctx))
+	if err != nil {
+		notInRoom(ctx)
+		return
+	}
+
+	logger.Infof("Device %s left room %s, %d members", ctx.ClientIP(), r.ID, r.Members)
+	if !closed {
+		c.hub.Publish(r.Namespace(), events.TypeRoomLeave, gin.H{"members": r.Members})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "已离开房间",
+		"closed":  closed,
+	})
+}
+
+// handleRoomError 将房间错误转换为响应
+func (c *RoomController) handleRoomError(ctx *gin.Context, err error) {
+	switch err {
+	case room.ErrInvalidTTL:
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidRoomRequest,
+			"message": "房间有效期无效",
+		})
+	case room.ErrRoomNotFound:
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    errors.ErrCodeRoomNotFound,
+			"message": "配对码无效或房间已过期",
+		})
+	case room.ErrRoomFull:
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"code":    errors.ErrCodeRoomFull,
+			"message": "房间设备数已达上限",
+		})
+	case room.ErrTooManyAttempts:
+		logger.Warnf("Too many failed room join attempts from %s", ctx.ClientIP())
+		ctx.JSON(http.StatusTooManyRequests, gin.H{
+			"code":    errors.ErrCodeTooManyJoinAttempts,
+			"message": "加入房间失败次数过多，请稍后再试",
+		})
+	case room.ErrTooManyRooms:
+		logger.Warnf("Room limit reached, %d rooms open", c.rooms.Count())
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    errors.ErrCodeTooManyRooms,
+			"message": "房间数量已达上限",
+		})
+	default:
+		logger.Errorf("Failed to create or join room: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeCreateRoomFailed,
+			"message": "创建或加入房间失败",
+		})
+	}
+}
+
+// notInRoom 返回当前请求不属于任何房间的响应
+func notInRoom(ctx *gin.Context) {
+	ctx.JSON(http.StatusNotFound, gin.H{
+		"code":    errors.ErrCodeRoomNotFound,
+		"message": "当前请求不属于任何房间",
+	})
+}
+
+// roomResponse 房间信息，joinUrl供客户端生成二维码
+func (c *RoomController) roomResponse(ctx *gin.Context, r *room.Room) gin.H {
+	return gin.H{
+		"id":        r.ID,
+		"code":      r.Code,
+		"createdAt": r.CreatedAt,
+		"expiresAt": r.ExpiresAt,
+		"members":   r.Members,
+		"joinUrl":   fmt.Sprintf("%s/?room=%s", baseURL(ctx, c.server), r.Code),
+	}
+}
+
+// baseURL 客户端访问服务使用的地址，用于生成可以分享给其他设备的链接；优先使用配置的PublicURL，
+// 否则根据请求推断，X-Forwarded-Proto和X-Forwarded-Host只在请求来自受信任的代理时使用
+func baseURL(ctx *gin.Context, server *config.ServerConfig) string {
+	if server.PublicURL != "" {
+		return strings.TrimSuffix(server.PublicURL, "/")
+	}
+
+	scheme, host := "http", ctx.Request.Host
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	if fromTrustedProxy(ctx, server.TrustedProxies) {
+		if proto := ctx.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := ctx.GetHeader("X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// fromTrustedProxy 请求的对端地址是否属于受信任的代理（IP或CIDR）
+func fromTrustedProxy(ctx *gin.Context, proxies []string) bool {
+	ip := net.ParseIP(ctx.RemoteIP())
+	if ip == nil {
+		return false
+	}
+
+	for _, proxy := range proxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(net.ParseIP(proxy)) {
+			return true
+		}
+	}
+
+	return false
+}
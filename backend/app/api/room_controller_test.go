@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/app/config"
+)
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  config.ServerConfig
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:   "request host",
+			remote: "203.0.113.5:1234",
+			want:   "http://clip.example.com",
+		},
+		{
+			name:    "forwarded headers from untrusted peer are ignored",
+			remote:  "203.0.113.5:1234",
+			headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example.com"},
+			want:    "http://clip.example.com",
+		},
+		{
+			name:    "forwarded headers from trusted proxy",
+			server:  config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remote:  "10.1.2.3:1234",
+			headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "public.example.com"},
+			want:    "https://public.example.com",
+		},
+		{
+			name:    "trusted proxy by address",
+			server:  config.ServerConfig{TrustedProxies: []string{"127.0.0.1"}},
+			remote:  "127.0.0.1:1234",
+			headers: map[string]string{"X-Forwarded-Proto": "https"},
+			want:    "https://clip.example.com",
+		},
+		{
+			name:    "public url wins",
+			server:  config.ServerConfig{PublicURL: "https://clip.example.org/", TrustedProxies: []string{"10.0.0.0/8"}},
+			remote:  "10.1.2.3:1234",
+			headers: map[string]string{"X-Forwarded-Proto": "http", "X-Forwarded-Host": "public.example.com"},
+			want:    "https://clip.example.org",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest("GET", "http://clip.example.com/api/rooms", nil)
+			ctx.Request.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				ctx.Request.Header.Set(k, v)
+			}
+
+			if got := baseURL(ctx, &tt.server); got != tt.want {
+				t.Fatalf("baseURL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,505 @@
+package api
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/clipboard"
+	"cloud-clipboard/internal/errors"
+	"cloud-clipboard/internal/events"
+	fileservice "cloud-clipboard/internal/file"
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/share"
+)
+
+// ShareController 分享链接控制器，链接的兑换不需要身份认证，文件下载仍然经过限速和下载次数检查
+type ShareController struct {
+	shares    *share.Store
+	files     *FileController
+	clipboard *ClipboardController
+	hub       *events.Hub
+	config    *config.ShareConfig
+	server    *config.ServerConfig
+}
+
+// NewShareController 创建新的分享链接控制器，server用于生成链接的地址
+func NewShareController(shares *share.Store, files *FileController, clipboard *ClipboardController, hub *events.Hub, config *config.ShareConfig, server *config.ServerConfig) *ShareController {
+	return &ShareController{
+		shares:    shares,
+		files:     files,
+		clipboard: clipboard,
+		hub:       hub,
+		config:    config,
+		server:    server,
+	}
+}
+
+// CreateShareRequest 创建分享链接请求
+type CreateShareRequest struct {
+	// ExpiresIn 有效期（毫秒），为空或0表示使用默认有效期
+	ExpiresIn int64 `json:"expiresIn"`
+	// MaxUses 允许使用次数，0表示不限制，为空时使用配置的上限
+	MaxUses *int `json:"maxUses"`
+	// Password 访问密码，兑换时通过X-File-Password请求头提供
+	Password string `json:"password"`
+}
+
+// ShareFile 分享文件
+// @Summary 分享文件
+// @Description 为当前命名空间的文件创建带签名的分享链接，链接携带有效期和允许使用次数，任何人都可以通过链接下载文件；
+// @Description 文件设置了访问密码时需要提供X-File-Password，链接可以设置自己的访问密码
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Param id path string true "文件ID"
+// @Param share body CreateShareRequest false "分享策略"
+// @Param X-File-Password header string false "文件的访问密码"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Router /api/files/{id}/share [post]
+func (c *ShareController) ShareFile(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	file, err := c.files.fileService.GetFileMetadata(currentNamespace(ctx), id)
+	if err != nil {
+		if err == fileservice.ErrFileNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"code":    errors.ErrCodeFileNotFound,
+				"message": "文件不存在",
+			})
+			return
+		}
+		logger.Errorf("Failed to get file info for share: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeGetFileInfoFailed,
+			"message": "获取文件信息失败",
+		})
+		return
+	}
+
+	// 分享受密码保护的文件需要知道文件的访问密码
+	if !c.files.checkFileAccess(ctx, file) {
+		return
+	}
+
+	c.createLink(ctx, share.KindFile, file.ID)
+}
+
+// ShareText 分享字符串
+// @Summary 分享字符串
+// @Description 为当前命名空间剪切板中的字符串创建带签名的分享链接，链接携带有效期和允许使用次数；
+// @Description 阅后即焚的字符串在第一次兑换后被删除
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Param id path string true "字符串ID"
+// @Param share body CreateShareRequest false "分享策略"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/clipboard/text/{id}/share [post]
+func (c *ShareController) ShareText(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	cache, ok := c.clipboard.cache(ctx)
+	if !ok {
+		return
+	}
+
+	if !containsText(cache.GetAll(), id) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": "Text not found",
+		})
+		return
+	}
+
+	c.createLink(ctx, share.KindText, id)
+}
+
+// ListShares 获取分享链接
+// @Summary 获取分享链接
+// @Description 获取当前命名空间创建的所有分享链接及其使用次数
+// @Tags shares
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/shares [get]
+func (c *ShareController) ListShares(ctx *gin.Context) {
+	links := c.shares.List(currentNamespace(ctx))
+
+	items := make([]gin.H, 0, len(links))
+	for _, link := range links {
+		items = append(items, c.linkResponse(ctx, link, c.shares.Token(link)))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"items": items,
+	})
+}
+
+// RevokeShare 吊销分享链接
+// @Summary 吊销分享链接
+// @Description 吊销当前命名空间的分享链接，链接立即失效，分享的内容不受影响
+// @Tags shares
+// @Produce json
+// @Param id path string true "链接ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/shares/{id} [delete]
+func (c *ShareController) RevokeShare(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	if err := c.shares.Revoke(currentNamespace(ctx), id); err != nil {
+		if err == share.ErrLinkNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"code":    errors.ErrCodeShareNotFound,
+				"message": "分享链接不存在",
+			})
+			return
+		}
+		logger.Errorf("Failed to revoke share link: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeUpdateShareFailed,
+			"message": "吊销分享链接失败",
+		})
+		return
+	}
+
+	logger.Infof("Share link %s revoked by %s", id, CurrentIdentity(ctx))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "分享链接已吊销",
+	})
+}
+
+// RedeemShare 兑换分享链接
+// @Summary 兑换分享链接
+// @Description 不需要身份认证，校验链接签名、有效期、使用次数和访问密码后返回分享的内容：文件直接下载（支持Range，按下载带宽限速，
+// @Description 同时计入文件的下载次数），字符串返回JSON。每次兑换计为一次使用，文件无法下载时退还，中断下载的续传不重复计数
+// @Tags shares
+// @Produce octet-stream
+// @Produce json
+// @Param token path string true "链接令牌"
+// @Param X-File-Password header string false "链接的访问密码"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Router /s/{token} [get]
+func (c *ShareController) RedeemShare(ctx *gin.Context) {
+	link, err := c.shares.Resolve(ctx.Param("token"))
+	if err != nil {
+		c.handleShareError(ctx, err)
+		return
+	}
+
+	if !c.checkSharePassword(ctx, link) {
+		return
+	}
+
+	switch link.Kind {
+	case share.KindFile:
+		c.redeemFile(ctx, link)
+	case share.KindText:
+		c.redeemText(ctx, link)
+	default:
+		shareNotFound(ctx)
+	}
+}
+
+// redeemFile 通过分享链接下载文件，文件的有效期和下载次数仍然有效，文件自身的访问密码由链接代替
+func (c *ShareController) redeemFile(ctx *gin.Context, link *share.Link) {
+	file, err := c.files.fileService.GetFileMetadata(link.Namespace, link.Target)
+	if err != nil {
+		if err == fileservice.ErrFileNotFound {
+			shareNotFound(ctx)
+			return
+		}
+		logger.Errorf("Failed to get file metadata for share: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeGetFileMetaFailed,
+			"message": "获取文件信息失败",
+		})
+		return
+	}
+
+	if file.Expired(time.Now().UnixMilli(), c.files.config.MaxAge) {
+		ctx.JSON(http.StatusGone, gin.H{
+			"code":    errors.ErrCodeFileExpired,
+			"message": "文件已过期",
+		})
+		return
+	}
+
+	// 先预留文件的下载次数再计数链接的使用次数，文件无法下载时不消耗链接
+	counted := false
+	c.files.serveDownload(ctx, file, &downloadHook{
+		acquire: func(resumed bool) bool {
+			var err error
+			if counted, err = c.shares.Use(link.ID, resumed); err != nil {
+				c.handleShareError(ctx, err)
+				return false
+			}
+			logger.Infof("Share link %s redeemed by %s for file %s", link.ID, ctx.ClientIP(), file.ID)
+			return true
+		},
+		release: func() {
+			if !counted {
+				return
+			}
+			if err := c.shares.Refund(link.ID); err != nil {
+				logger.Errorf("Failed to refund share link %s: %v", link.ID, err)
+			}
+		},
+	})
+}
+
+// redeemText 通过分享链接获取字符串
+func (c *ShareController) redeemText(ctx *gin.Context, link *share.Link) {
+	// 只查找不创建，命名空间已不存在时链接指向的字符串同样不存在
+	cache, err := c.clipboard.spaces.Lookup(link.Namespace)
+	if err == clipboard.ErrNamespaceNotFound {
+		shareNotFound(ctx)
+		return
+	}
+	if err != nil {
+		logger.Errorf("Failed to open clipboard for share %s: %v", link.ID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to open clipboard",
+		})
+		return
+	}
+
+	// 先确认字符串仍然存在，避免在内容已被删除时消耗使用次数
+	if !containsText(cache.GetAll(), link.Target) {
+		shareNotFound(ctx)
+		return
+	}
+	if _, err := c.shares.Use(link.ID, false); err != nil {
+		c.handleShareError(ctx, err)
+		return
+	}
+
+	item, ok := cache.Get(link.Target)
+	if !ok {
+		// 检查之后字符串已被删除（例如阅后即焚已被读取），退还使用次数
+		if err := c.shares.Refund(link.ID); err != nil {
+			logger.Errorf("Failed to refund share link %s: %v", link.ID, err)
+		}
+		shareNotFound(ctx)
+		return
+	}
+	if item.ReadOnce {
+		c.hub.Publish(link.Namespace, events.TypeClipboardDelete, gin.H{"id": link.Target})
+	}
+	logger.Infof("Share link %s redeemed by %s for text %s", link.ID, ctx.ClientIP(), link.Target)
+
+	response := gin.H{
+		"id":       link.Target,
+		"text":     item.Value,
+		"readOnce": item.ReadOnce,
+	}
+	if item.ExpiresAt > 0 {
+		response["expiresAt"] = item.ExpiresAt
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// createLink 按请求中的策略创建分享链接并返回
+func (c *ShareController) createLink(ctx *gin.Context, kind, target string) {
+	var req CreateShareRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    errors.ErrCodeInvalidShareRequest,
+				"message": "分享参数无效",
+			})
+			return
+		}
+	}
+
+	policy, err := parseLinkPolicy(&req, c.config)
+	if err != nil {
+		var policyErr sharePolicyError
+		if stderrors.As(err, &policyErr) {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    errors.ErrCodeInvalidShareRequest,
+				"message": policyErr.Error(),
+			})
+			return
+		}
+		logger.Errorf("Failed to parse share link policy: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeCreateShareFailed,
+			"message": "创建分享链接失败",
+		})
+		return
+	}
+
+	identity := CurrentIdentity(ctx)
+	link, token, err := c.shares.Create(kind, target, currentNamespace(ctx), identity.String(), policy)
+	if err != nil {
+		logger.Errorf("Failed to create share link: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeCreateShareFailed,
+			"message": "创建分享链接失败",
+		})
+		return
+	}
+
+	logger.Infof("Share link %s for %s %s created by %s, expires at %d", link.ID, kind, target, identity, link.ExpiresAt)
+
+	ctx.JSON(http.StatusCreated, c.linkResponse(ctx, link, token))
+}
+
+// linkResponse 分享链接信息，url为可以直接发送给其他人的兑换地址
+func (c *ShareController) linkResponse(ctx *gin.Context, link *share.Link, token string) gin.H {
+	return gin.H{
+		"id":        link.ID,
+		"kind":      link.Kind,
+		"target":    link.Target,
+		"createdAt": link.CreatedAt,
+		"expiresAt": link.ExpiresAt,
+		"maxUses":   link.MaxUses,
+		"uses":      link.Uses,
+		"protected": link.Protected(),
+		"token":     token,
+		"url":       fmt.Sprintf("%s/s/%s", baseURL(ctx, c.server), token),
+	}
+}
+
+// handleShareError 将分享链接错误转换为响应
+func (c *ShareController) handleShareError(ctx *gin.Context, err error) {
+	switch err {
+	case share.ErrInvalidLink:
+		logger.Warnf("Invalid share link from %s", ctx.ClientIP())
+		shareNotFound(ctx)
+	case share.ErrLinkNotFound:
+		shareNotFound(ctx)
+	case share.ErrLinkExpired:
+		ctx.JSON(http.StatusGone, gin.H{
+			"code":    errors.ErrCodeShareExpired,
+			"message": "分享链接已过期",
+		})
+	case share.ErrUsesExhausted:
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"code":    errors.ErrCodeShareUsesExhausted,
+			"message": "分享链接使用次数已用完",
+		})
+	default:
+		logger.Errorf("Failed to redeem share link: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeUpdateShareFailed,
+			"message": "更新分享链接失败",
+		})
+	}
+}
+
+// shareNotFound 返回分享链接无效、已吊销或内容已被删除的响应，不区分具体原因
+func shareNotFound(ctx *gin.Context) {
+	ctx.JSON(http.StatusNotFound, gin.H{
+		"code":    errors.ErrCodeShareNotFound,
+		"message": "分享链接无效或内容已被删除",
+	})
+}
+
+// parseLinkPolicy 解析分享链接的策略并按配置的上限校验，未设置的字段使用默认值
+func parseLinkPolicy(req *CreateShareRequest, cfg *config.ShareConfig) (share.Policy, error) {
+	policy := share.Policy{
+		ExpiresIn: cfg.TTL,
+		MaxUses:   cfg.MaxUses,
+	}
+
+	if req.ExpiresIn < 0 {
+		return policy, sharePolicyError("有效期必须为正整数（毫秒）")
+	}
+	if req.ExpiresIn > 0 {
+		if cfg.MaxTTL > 0 && req.ExpiresIn > cfg.MaxTTL {
+			return policy, sharePolicyError(fmt.Sprintf("有效期不能超过%v小时", cfg.MaxTTL/(60*60*1000)))
+		}
+		policy.ExpiresIn = req.ExpiresIn
+	}
+
+	if req.MaxUses != nil {
+		maxUses := *req.MaxUses
+		if maxUses < 0 {
+			return policy, sharePolicyError("使用次数必须为非负整数，0表示不限制")
+		}
+		if cfg.MaxUses > 0 && maxUses == 0 {
+			return policy, sharePolicyError(fmt.Sprintf("不允许设置为不限使用次数，最多%d次", cfg.MaxUses))
+		}
+		if cfg.MaxUses > 0 && maxUses > cfg.MaxUses {
+			return policy, sharePolicyError(fmt.Sprintf("使用次数不能超过%d次", cfg.MaxUses))
+		}
+		policy.MaxUses = maxUses
+	}
+
+	if req.Password != "" {
+		if len(req.Password) > fileservice.MaxPasswordLength {
+			return policy, sharePolicyError(fmt.Sprintf("访问密码不能超过%d字节", fileservice.MaxPasswordLength))
+		}
+		hash, err := fileservice.HashPassword(req.Password)
+		if err != nil {
+			return policy, err
+		}
+		policy.PasswordHash = hash
+	}
+
+	return policy, nil
+}
+
+// checkSharePassword 检查分享链接的访问密码，不允许访问时已写入响应并返回false；
+// 与文件一样只能通过X-File-Password请求头提供，失败次数限制和成功结果的缓存与文件共用
+func (c *ShareController) checkSharePassword(ctx *gin.Context, link *share.Link) bool {
+	if !link.Protected() {
+		return true
+	}
+
+	password := ctx.GetHeader("X-File-Password")
+	if password == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    errors.ErrCodeSharePasswordRequired,
+			"message": "需要访问密码",
+		})
+		return false
+	}
+
+	err := c.files.passwords.verify(rateLimitKey(ctx), "share:"+link.ID, link.PasswordHash, password, link.CheckPassword)
+	switch err {
+	case nil:
+		return true
+	case errTooManyPasswordTries:
+		logger.Warnf("Too many password attempts for share link %s from %s", link.ID, ctx.ClientIP())
+		tooManyPasswordAttempts(ctx)
+	default:
+		logger.Warnf("Incorrect password for share link %s from %s", link.ID, ctx.ClientIP())
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    errors.ErrCodeSharePasswordIncorrect,
+			"message": "访问密码错误",
+		})
+	}
+
+	return false
+}
+
+// containsText 剪切板中是否存在未过期的字符串，不读取内容，阅后即焚的字符串不会被删除
+func containsText(items []*clipboard.CacheItem, id string) bool {
+	for _, item := range items {
+		if item.Key == id {
+			return true
+		}
+	}
+
+	return false
+}
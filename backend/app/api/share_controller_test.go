@@ -0,0 +1,145 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/events"
+	fileservice "cloud-clipboard/internal/file"
+	"cloud-clipboard/internal/share"
+)
+
+// newTestShareController 创建使用临时目录的分享链接控制器，路由额外注册了兑换接口
+func newTestShareController(t *testing.T) (*FileController, *share.Store, *gin.Engine) {
+	t.Helper()
+
+	files, r := newTestFileController(t)
+	signer, err := share.NewSigner("test-secret", "")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	shares, err := share.NewStore(filepath.Join(t.TempDir(), "shares.json"), signer)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	c := NewShareController(shares, files, nil, events.NewHub(0), &config.ShareConfig{}, &config.ServerConfig{})
+	r.GET("/s/:token", c.RedeemShare)
+
+	return files, shares, r
+}
+
+func TestRedeemShareConsumesLinkOnlyWhenFileIsServed(t *testing.T) {
+	files, shares, r := newTestShareController(t)
+
+	file, err := files.fileService.AddFile(strings.NewReader("0123456789"), &fileservice.FileInfo{
+		OriginalName: "test.txt",
+		Size:         10,
+		SharePolicy:  fileservice.SharePolicy{MaxDownloads: 2},
+	})
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	link, token, err := shares.Create(share.KindFile, file.ID, "", "", share.Policy{
+		ExpiresIn: int64(time.Hour / time.Millisecond),
+		MaxUses:   3,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	tests := []struct {
+		rangeHeader string
+		want        int
+		uses        int
+	}{
+		{"bytes=0-4", http.StatusPartialContent, 1},
+		// 续传不重复计数
+		{"bytes=5-", http.StatusPartialContent, 1},
+		{"bytes=0-4", http.StatusPartialContent, 2},
+		// 文件的下载次数已用完，不消耗链接的使用次数
+		{"", http.StatusForbidden, 2},
+		{"bytes=6-", http.StatusForbidden, 2},
+		// 完成最后一次下载的续传后文件被删除
+		{"bytes=3-", http.StatusPartialContent, 2},
+		{"", http.StatusNotFound, 2},
+	}
+
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/s/"+token, nil)
+		if tt.rangeHeader != "" {
+			req.Header.Set("Range", tt.rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Fatalf("request %d: status = %d, want %d: %s", i, w.Code, tt.want, w.Body.String())
+		}
+		if uses := shares.List("")[0].Uses; uses != tt.uses {
+			t.Fatalf("request %d: link %s uses = %d, want %d", i, link.ID, uses, tt.uses)
+		}
+	}
+}
+
+func TestRedeemSingleUseLinkResumesInterruptedDownload(t *testing.T) {
+	// 文件只允许下载一次时完成续传后被删除
+	for maxDownloads, final := range map[int]int{0: http.StatusForbidden, 1: http.StatusNotFound} {
+		t.Run(fmt.Sprintf("maxDownloads=%d", maxDownloads), func(t *testing.T) {
+			files, shares, r := newTestShareController(t)
+			file, err := files.fileService.AddFile(strings.NewReader("0123456789"), &fileservice.FileInfo{
+				OriginalName: "test.txt",
+				Size:         10,
+				SharePolicy:  fileservice.SharePolicy{MaxDownloads: maxDownloads},
+			})
+			if err != nil {
+				t.Fatalf("AddFile: %v", err)
+			}
+			_, token, err := shares.Create(share.KindFile, file.ID, "", "", share.Policy{
+				ExpiresIn: int64(time.Hour / time.Millisecond),
+				MaxUses:   1,
+			})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			tests := []struct {
+				rangeHeader string
+				want        int
+				body        string
+			}{
+				// 下载在传输5个字节后中断
+				{"bytes=0-4", http.StatusPartialContent, "01234"},
+				// 续传不需要新的使用次数
+				{"bytes=5-", http.StatusPartialContent, "56789"},
+				// 完整下载后链接不能再次使用
+				{"", final, ""},
+			}
+			for i, tt := range tests {
+				req := httptest.NewRequest(http.MethodGet, "/s/"+token, nil)
+				if tt.rangeHeader != "" {
+					req.Header.Set("Range", tt.rangeHeader)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != tt.want {
+					t.Fatalf("request %d: status = %d, want %d: %s", i, w.Code, tt.want, w.Body.String())
+				}
+				if tt.body != "" && w.Body.String() != tt.body {
+					t.Fatalf("request %d: body = %q, want %q", i, w.Body.String(), tt.body)
+				}
+				if uses := shares.List("")[0].Uses; uses != 1 {
+					t.Fatalf("request %d: uses = %d, want 1", i, uses)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/errors"
+	fileservice "cloud-clipboard/internal/file"
+	"cloud-clipboard/internal/logger"
+)
+
+// sharePolicyError 分享策略参数无效，错误信息直接返回给客户端
+type sharePolicyError string
+
+// Error 错误信息
+func (e sharePolicyError) Error() string {
+	return string(e)
+}
+
+// parseSharePolicy 解析上传者设置的分享策略并按管理员配置的上限校验：
+// expiresIn为有效期（毫秒），maxDownloads为最大下载次数（0表示不限制），password为访问密码；
+// 未设置的字段使用默认值，即maxAge和maxDownloads（超过上限时取上限）
+func parseSharePolicy(values map[string]string, cfg *config.FileConfig) (fileservice.SharePolicy, error) {
+	policy := fileservice.SharePolicy{
+		ExpiresIn:    cfg.MaxAge,
+		MaxDownloads: cfg.MaxDownloads,
+	}
+	if cfg.MaxExpiresIn > 0 && policy.ExpiresIn > cfg.MaxExpiresIn {
+		policy.ExpiresIn = cfg.MaxExpiresIn
+	}
+	if cfg.DownloadCeiling > 0 && (policy.MaxDownloads == 0 || policy.MaxDownloads > cfg.DownloadCeiling) {
+		policy.MaxDownloads = cfg.DownloadCeiling
+	}
+
+	if v := values["expiresIn"]; v != "" {
+		expiresIn, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || expiresIn <= 0 {
+			return policy, sharePolicyError("有效期必须为正整数（毫秒）")
+		}
+		if cfg.MaxExpiresIn > 0 && expiresIn > cfg.MaxExpiresIn {
+			return policy, sharePolicyError(fmt.Sprintf("有效期不能超过%v小时", cfg.MaxExpiresIn/(60*60*1000)))
+		}
+		policy.ExpiresIn = expiresIn
+	}
+
+	if v := values["maxDownloads"]; v != "" {
+		maxDownloads, err := strconv.Atoi(v)
+		if err != nil || maxDownloads < 0 {
+			return policy, sharePolicyError("下载次数必须为非负整数，0表示不限制")
+		}
+		if cfg.DownloadCeiling > 0 && maxDownloads == 0 {
+			return policy, sharePolicyError(fmt.Sprintf("不允许设置为不限下载次数，最多%d次", cfg.DownloadCeiling))
+		}
+		if cfg.DownloadCeiling > 0 && maxDownloads > cfg.DownloadCeiling {
+			return policy, sharePolicyError(fmt.Sprintf("下载次数不能超过%d次", cfg.DownloadCeiling))
+		}
+		policy.MaxDownloads = maxDownloads
+	}
+
+	if password := values["password"]; password != "" {
+		if len(password) > fileservice.MaxPasswordLength {
+			return policy, sharePolicyError(fmt.Sprintf("访问密码不能超过%d字节", fileservice.MaxPasswordLength))
+		}
+		hash, err := fileservice.HashPassword(password)
+		if err != nil {
+			return policy, err
+		}
+		policy.PasswordHash = hash
+	}
+
+	return policy, nil
+}
+
+// handleSharePolicyError 返回分享策略解析失败的响应
+func handleSharePolicyError(ctx *gin.Context, err error) {
+	var policyErr sharePolicyError
+	if stderrors.As(err, &policyErr) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidSharePolicy,
+			"message": policyErr.Error(),
+		})
+		return
+	}
+
+	logger.Errorf("Failed to parse share policy: %v", err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{
+		"code":    errors.ErrCodeSharePolicyFailed,
+		"message": "处理分享策略失败",
+	})
+}
+
+// checkFileAccess 检查文件是否已过期以及访问密码，不允许访问时已写入响应并返回false；
+// 密码只能通过X-File-Password请求头提供，避免出现在访问日志中
+func (c *FileController) checkFileAccess(ctx *gin.Context, file *fileservice.FileMetadata) bool {
+	if file.Expired(time.Now().UnixMilli(), c.config.MaxAge) {
+		ctx.JSON(http.StatusGone, gin.H{
+			"code":    errors.ErrCodeFileExpired,
+			"message": "文件已过期",
+		})
+		return false
+	}
+
+	if !file.Protected() {
+		return true
+	}
+
+	password := ctx.GetHeader("X-File-Password")
+	if password == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    errors.ErrCodeFilePasswordRequired,
+			"message": "需要访问密码",
+		})
+		return false
+	}
+
+	err := c.passwords.verify(rateLimitKey(ctx), "file:"+file.ID, file.PasswordHash, password, file.CheckPassword)
+	switch err {
+	case nil:
+		return true
+	case errTooManyPasswordTries:
+		logger.Warnf("Too many password attempts for file %s from %s", file.ID, ctx.ClientIP())
+		tooManyPasswordAttempts(ctx)
+	default:
+		logger.Warnf("Incorrect password for file %s from %s", file.ID, ctx.ClientIP())
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    errors.ErrCodeFilePasswordIncorrect,
+			"message": "访问密码错误",
+		})
+	}
+
+	return false
+}
+
+// tooManyPasswordAttempts 返回密码错误次数过多的响应
+func tooManyPasswordAttempts(ctx *gin.Context) {
+	ctx.Header("Retry-After", strconv.Itoa(int(passwordAttemptWindow/time.Second)))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{
+		"code":    errors.ErrCodeTooManyPasswordAttempts,
+		"message": "访问密码错误次数过多，请稍后再试",
+	})
+}
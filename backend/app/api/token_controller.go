@@ -0,0 +1,164 @@
+package api
+
+import (
+	stderrors "errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/internal/auth"
+	"cloud-clipboard/internal/errors"
+	"cloud-clipboard/internal/logger"
+)
+
+// TokenController API令牌管理控制器
+type TokenController struct {
+	tokens *auth.TokenStore
+}
+
+// NewTokenController 创建新的API令牌管理控制器
+func NewTokenController(tokens *auth.TokenStore) *TokenController {
+	return &TokenController{tokens: tokens}
+}
+
+// CreateTokenRequest 创建令牌请求
+type CreateTokenRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+	// ExpiresIn 有效期（毫秒），为空或0表示永不过期
+	ExpiresIn int64 `json:"expiresIn"`
+	// Namespace 绑定的命名空间，为空时使用名为"token-<ID>"的独立空间，public表示公共命名空间，
+	// 绑定到同一命名空间的令牌共用剪切板和文件；"token-"开头和8位十六进制的名称保留给令牌的独立空间
+	Namespace string `json:"namespace"`
+}
+
+// CreateToken 创建令牌
+// @Summary 创建API令牌
+// @Description 创建API令牌，令牌明文只在响应中返回一次，服务端只保存摘要。
+// @Description 可用的权限范围：clipboard:read、clipboard:write、files:read、files:write、admin（包含所有权限）
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param token body CreateTokenRequest true "令牌名称、权限范围、有效期和命名空间"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/tokens [post]
+func (c *TokenController) CreateToken(ctx *gin.Context) {
+	var req CreateTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.ExpiresIn < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidTokenRequest,
+			"message": "令牌参数无效",
+		})
+		return
+	}
+
+	secret, token, err := c.tokens.Create(req.Name, req.Namespace, req.Scopes, req.ExpiresIn)
+	if stderrors.Is(err, auth.ErrInvalidScope) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidTokenRequest,
+			"message": "权限范围无效",
+		})
+		return
+	}
+	if stderrors.Is(err, auth.ErrInvalidNamespace) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidTokenRequest,
+			"message": "命名空间名称无效或已保留",
+		})
+		return
+	}
+	if err != nil {
+		logger.Errorf("Failed to create token: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeCreateTokenFailed,
+			"message": "创建令牌失败",
+		})
+		return
+	}
+
+	logger.Infof("API token %s(%s) created by %s with scopes %v in namespace %s", token.Name, token.ID, CurrentIdentity(ctx), token.Scopes, token.NamespaceName())
+
+	response := tokenResponse(token)
+	response["token"] = secret
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// ListTokens 获取所有令牌
+// @Summary 获取API令牌列表
+// @Description 获取所有API令牌的信息，不包含令牌明文和摘要
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/tokens [get]
+func (c *TokenController) ListTokens(ctx *gin.Context) {
+	tokens, err := c.tokens.List()
+	if err != nil {
+		logger.Errorf("Failed to list tokens: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeListTokensFailed,
+			"message": "获取令牌列表失败",
+		})
+		return
+	}
+
+	result := make([]gin.H, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, tokenResponse(token))
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"tokens": result,
+	})
+}
+
+// RevokeToken 吊销令牌
+// @Summary 吊销API令牌
+// @Description 根据ID吊销API令牌，立即生效
+// @Tags admin
+// @Produce json
+// @Param id path string true "令牌ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/admin/tokens/{id} [delete]
+func (c *TokenController) RevokeToken(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	if err := c.tokens.Revoke(id); err != nil {
+		if err == auth.ErrTokenNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"code":    errors.ErrCodeTokenNotFound,
+				"message": "令牌不存在",
+			})
+			return
+		}
+		logger.Errorf("Failed to revoke token: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeRevokeTokenFailed,
+			"message": "吊销令牌失败",
+		})
+		return
+	}
+
+	logger.Infof("API token %s revoked by %s", id, CurrentIdentity(ctx))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "令牌已吊销",
+	})
+}
+
+// tokenResponse 令牌信息，不包含摘要
+func tokenResponse(token *auth.Token) gin.H {
+	return gin.H{
+		"id":         token.ID,
+		"name":       token.Name,
+		"scopes":     token.Scopes,
+		"namespace":  token.NamespaceName(),
+		"createdAt":  token.CreatedAt,
+		"expiresAt":  token.ExpiresAt,
+		"lastUsedAt": token.LastUsedAt,
+	}
+}
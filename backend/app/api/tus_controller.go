@@ -0,0 +1,473 @@
+package api
+
+import (
+	"encoding/base64"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/errors"
+	"cloud-clipboard/internal/events"
+	fileservice "cloud-clipboard/internal/file"
+	"cloud-clipboard/internal/logger"
+)
+
+// tus协议相关常量
+const (
+	tusVersion         = "1.0.0"
+	tusExtensions      = "creation,termination"
+	tusOffsetOctetType = "application/offset+octet-stream"
+)
+
+// errTusStorageExceeded 上传完成时总存储容量已超过限制
+var errTusStorageExceeded = stderrors.New("total storage limit exceeded")
+
+// TusController 断点续传上传控制器，实现tus 1.0.0协议的creation和termination扩展
+type TusController struct {
+	fileService *fileservice.FileService
+	uploads     *fileservice.UploadManager
+	hub         *events.Hub
+	config      *config.FileConfig
+}
+
+// NewTusController 创建新的断点续传上传控制器
+func NewTusController(fileService *fileservice.FileService, uploads *fileservice.UploadManager, hub *events.Hub, config *config.FileConfig) *TusController {
+	return &TusController{
+		fileService: fileService,
+		uploads:     uploads,
+		hub:         hub,
+		config:      config,
+	}
+}
+
+// Options 获取服务端支持的tus协议信息
+// @Summary 获取断点续传协议信息
+// @Description 返回服务端支持的tus协议版本、扩展和最大上传大小
+// @Tags files
+// @Success 204
+// @Router /api/files/tus [options]
+func (c *TusController) Options(ctx *gin.Context) {
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Header("Tus-Version", tusVersion)
+	ctx.Header("Tus-Extension", tusExtensions)
+	ctx.Header("Tus-Max-Size", strconv.FormatInt(c.config.MaxFileSize, 10))
+	ctx.Status(http.StatusNoContent)
+}
+
+// CreateUpload 创建上传会话
+// @Summary 创建断点续传上传
+// @Description 根据Upload-Length和Upload-Metadata创建上传会话，返回的Location用于后续上传
+// @Tags files
+// @Param Tus-Resumable header string true "tus协议版本"
+// @Param Upload-Length header int true "文件总大小"
+// @Param Upload-Metadata header string false "文件元数据，包含base64编码的filename、filetype以及可选的expiresIn、maxDownloads、password"
+// @Param X-Content-SHA256 header string false "完整文件期望的SHA-256摘要（十六进制），也可以使用Digest: sha-256=<base64>"
+// @Success 201
+// @Failure 400 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/files/tus [post]
+func (c *TusController) CreateUpload(ctx *gin.Context) {
+	if !c.checkVersion(ctx) {
+		return
+	}
+
+	length, err := strconv.ParseInt(ctx.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidUploadRequest,
+			"message": "Upload-Length无效",
+		})
+		return
+	}
+
+	// 检查文件大小
+	if length > c.config.MaxFileSize {
+		logger.Warnf("File size exceeds maximum limit: %d, max allowed: %d", length, c.config.MaxFileSize)
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"code":    errors.ErrCodeFileSizeExceeded,
+			"message": fmt.Sprintf("文件大小超过限制（最大%vMB）", c.config.MaxFileSize/(1024*1024)),
+		})
+		return
+	}
+
+	// 检查总存储限制，上传完成时会再次检查
+	if !c.checkStorage(ctx, length) {
+		return
+	}
+
+	metadata, err := parseUploadMetadata(ctx.GetHeader("Upload-Metadata"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidUploadRequest,
+			"message": "Upload-Metadata无效",
+		})
+		return
+	}
+
+	// 客户端可以提供完整文件期望的SHA-256摘要，上传完成时校验
+	digest, err := expectedDigest(ctx.Request.Header)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidChecksum,
+			"message": "校验和格式无效",
+		})
+		return
+	}
+
+	filename := filepath.Base(metadata["filename"])
+	if filename == "." || filename == string(filepath.Separator) {
+		filename = "upload"
+	}
+
+	// 上传者设置的有效期、下载次数和访问密码，会话中只保存密码的哈希
+	policy, err := parseSharePolicy(metadata, c.config)
+	if err != nil {
+		handleSharePolicyError(ctx, err)
+		return
+	}
+
+	session, err := c.uploads.Create(currentNamespace(ctx), length, filename, metadata["filetype"], digest, policy)
+	if err != nil {
+		logger.Errorf("Failed to create upload session: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeCreateUploadFailed,
+			"message": "创建上传会话失败",
+		})
+		return
+	}
+
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Header("Location", c.location(ctx, session.ID))
+
+	// 空文件无需上传数据，直接完成
+	if length == 0 {
+		if !c.completeUpload(ctx, session.ID) {
+			return
+		}
+	}
+
+	ctx.Status(http.StatusCreated)
+}
+
+// GetUploadOffset 获取上传进度
+// @Summary 获取断点续传进度
+// @Description 返回已上传的偏移量和文件总大小
+// @Tags files
+// @Param Tus-Resumable header string true "tus协议版本"
+// @Param id path string true "上传会话ID"
+// @Success 200
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/files/tus/{id} [head]
+func (c *TusController) GetUploadOffset(ctx *gin.Context) {
+	if !c.checkVersion(ctx) {
+		return
+	}
+
+	session, err := c.session(ctx, ctx.Param("id"))
+	if err != nil {
+		c.handleUploadError(ctx, err)
+		return
+	}
+
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	ctx.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(http.StatusOK)
+}
+
+// PatchUpload 上传数据分片
+// @Summary 上传断点续传数据
+// @Description 从Upload-Offset处追加数据，全部上传完成后登记为文件，文件ID通过Upload-File-Id返回
+// @Tags files
+// @Accept application/offset+octet-stream
+// @Param Tus-Resumable header string true "tus协议版本"
+// @Param Upload-Offset header int true "本次上传的起始偏移量"
+// @Param id path string true "上传会话ID"
+// @Success 204
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 415 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/files/tus/{id} [patch]
+func (c *TusController) PatchUpload(ctx *gin.Context) {
+	if !c.checkVersion(ctx) {
+		return
+	}
+
+	if ctx.ContentType() != tusOffsetOctetType {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"code":    errors.ErrCodeInvalidUploadContentType,
+			"message": "Content-Type必须为" + tusOffsetOctetType,
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(ctx.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidUploadRequest,
+			"message": "Upload-Offset无效",
+		})
+		return
+	}
+
+	id := ctx.Param("id")
+	session, err := c.session(ctx, id)
+	if err != nil {
+		c.handleUploadError(ctx, err)
+		return
+	}
+
+	if ctx.Request.ContentLength > session.Length-offset {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidUploadRequest,
+			"message": "上传数据超过文件总大小",
+		})
+		return
+	}
+
+	session, err = c.uploads.Append(id, offset, ctx.Request.Body)
+	if err != nil {
+		if session != nil {
+			ctx.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		}
+		c.handleUploadError(ctx, err)
+		return
+	}
+
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+
+	if session.Offset == session.Length {
+		if !c.completeUpload(ctx, id) {
+			return
+		}
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// DeleteUpload 终止上传
+// @Summary 终止断点续传上传
+// @Description 删除上传会话及已上传的数据
+// @Tags files
+// @Param Tus-Resumable header string true "tus协议版本"
+// @Param id path string true "上传会话ID"
+// @Success 204
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/files/tus/{id} [delete]
+func (c *TusController) DeleteUpload(ctx *gin.Context) {
+	if !c.checkVersion(ctx) {
+		return
+	}
+
+	id := ctx.Param("id")
+	if _, err := c.session(ctx, id); err != nil {
+		c.handleUploadError(ctx, err)
+		return
+	}
+	if err := c.uploads.Remove(id); err != nil {
+		c.handleUploadError(ctx, err)
+		return
+	}
+
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Status(http.StatusNoContent)
+}
+
+// completeUpload 将已完成的上传写入存储并登记文件元数据，失败时已写入响应并返回false
+func (c *TusController) completeUpload(ctx *gin.Context, id string) bool {
+	var metadata *fileservice.FileMetadata
+	err := c.uploads.Complete(id, func(session *fileservice.UploadSession, r io.Reader) error {
+		// 上传期间其他文件可能已占用存储空间，完成时预留空间后再写入
+		release, err := c.fileService.ReserveStorage(session.Owner, session.Length, c.config.MaxStorage, c.config.NamespaceQuota)
+		if err == fileservice.ErrStorageExceeded {
+			logger.Warnf("Total storage limit exceeded. Max: %d, New file: %d", c.config.MaxStorage, session.Length)
+			return errTusStorageExceeded
+		}
+		if err == fileservice.ErrQuotaExceeded {
+			logger.Warnf("Storage quota exceeded. Namespace quota: %d, New file: %d", c.config.NamespaceQuota, session.Length)
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check total storage: %w", err)
+		}
+		defer release()
+
+		metadata, err = c.fileService.AddFile(r, &fileservice.FileInfo{
+			Owner:          session.Owner,
+			OriginalName:   session.Filename,
+			Size:           session.Length,
+			Mimetype:       session.Mimetype,
+			ExpectedDigest: session.Digest,
+			SharePolicy:    session.SharePolicy,
+		})
+		return err
+	})
+	if err == fileservice.ErrFileTypeNotAllowed {
+		if err := c.uploads.Remove(id); err != nil {
+			logger.Errorf("Failed to remove upload session %s: %v", id, err)
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeInvalidFileFormat,
+			"message": "不允许上传该类型的文件",
+		})
+		return false
+	}
+	if err == fileservice.ErrChecksumMismatch {
+		// 已上传的数据无法修复，删除会话后客户端需要重新上传
+		logger.Warnf("Upload checksum mismatch: %s", id)
+		if err := c.uploads.Remove(id); err != nil {
+			logger.Errorf("Failed to remove upload session %s: %v", id, err)
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeChecksumMismatch,
+			"message": "文件内容与校验和不一致",
+		})
+		return false
+	}
+	if err != nil {
+		c.handleUploadError(ctx, err)
+		return false
+	}
+
+	ctx.Header("Upload-File-Id", metadata.ID)
+	c.hub.Publish(metadata.Owner, events.TypeFileUpload, map[string]interface{}{
+		"id":           metadata.ID,
+		"filename":     metadata.Filename,
+		"size":         metadata.Size,
+		"mimetype":     metadata.Mimetype,
+		"digest":       metadata.Digest,
+		"uploadTime":   metadata.UploadTime,
+		"expiresAt":    metadata.ExpiresAt,
+		"maxDownloads": metadata.MaxDownloads,
+		"protected":    metadata.Protected(),
+	})
+
+	return true
+}
+
+// checkVersion 检查客户端的tus协议版本，不支持时已写入响应并返回false
+func (c *TusController) checkVersion(ctx *gin.Context) bool {
+	if ctx.GetHeader("Tus-Resumable") == tusVersion {
+		return true
+	}
+
+	ctx.Header("Tus-Version", tusVersion)
+	ctx.JSON(http.StatusPreconditionFailed, gin.H{
+		"code":    errors.ErrCodeUnsupportedTusVersion,
+		"message": "不支持的tus协议版本",
+	})
+	return false
+}
+
+// checkStorage 检查总存储限制和命名空间存储限制，超过时已写入响应并返回false
+func (c *TusController) checkStorage(ctx *gin.Context, size int64) bool {
+	err := c.fileService.CheckStorage(currentNamespace(ctx), size, c.config.MaxStorage, c.config.NamespaceQuota)
+	switch err {
+	case nil:
+		return true
+	case fileservice.ErrStorageExceeded:
+		logger.Warnf("Total storage limit exceeded. Max: %d, New file: %d", c.config.MaxStorage, size)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeTotalStorageExceeded,
+			"message": "总存储容量超过限制",
+		})
+	case fileservice.ErrQuotaExceeded:
+		logger.Warnf("Storage quota of namespace %s exceeded. Quota: %d, New file: %d", CurrentIdentity(ctx).NamespaceName(), c.config.NamespaceQuota, size)
+		namespaceQuotaExceeded(ctx)
+	default:
+		logger.Errorf("Failed to check total storage: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeCheckStorageFailed,
+			"message": "检查总存储大小失败",
+		})
+	}
+
+	return false
+}
+
+// session 获取当前命名空间的上传会话，其他命名空间的会话视为不存在
+func (c *TusController) session(ctx *gin.Context, id string) (*fileservice.UploadSession, error) {
+	session, err := c.uploads.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Owner != currentNamespace(ctx) {
+		return nil, fileservice.ErrUploadNotFound
+	}
+
+	return session, nil
+}
+
+// handleUploadError 将上传会话错误转换为响应
+func (c *TusController) handleUploadError(ctx *gin.Context, err error) {
+	ctx.Header("Tus-Resumable", tusVersion)
+
+	switch {
+	case stderrors.Is(err, fileservice.ErrUploadNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    errors.ErrCodeUploadNotFound,
+			"message": "上传会话不存在",
+		})
+	case stderrors.Is(err, fileservice.ErrUploadOffsetMismatch):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"code":    errors.ErrCodeUploadOffsetMismatch,
+			"message": "上传偏移量与服务端不一致",
+		})
+	case stderrors.Is(err, errTusStorageExceeded):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    errors.ErrCodeTotalStorageExceeded,
+			"message": "总存储容量超过限制",
+		})
+	case stderrors.Is(err, fileservice.ErrQuotaExceeded):
+		namespaceQuotaExceeded(ctx)
+	default:
+		logger.Errorf("Failed to process upload: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    errors.ErrCodeWriteUploadFailed,
+			"message": "写入上传数据失败",
+		})
+	}
+}
+
+// location 上传会话的访问地址
+func (c *TusController) location(ctx *gin.Context, id string) string {
+	return strings.TrimSuffix(ctx.Request.URL.Path, "/") + "/" + id
+}
+
+// parseUploadMetadata 解析Upload-Metadata请求头，格式为逗号分隔的"键 base64值"
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			metadata[parts[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[parts[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("invalid metadata pair: %q", pair)
+		}
+	}
+
+	return metadata, nil
+}
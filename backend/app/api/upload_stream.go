@@ -0,0 +1,68 @@
+package api
+
+import (
+	stderrors "errors"
+	"io"
+	"mime/multipart"
+)
+
+// maxFieldSize 文件之前的普通表单字段允许的最大长度
+const maxFieldSize = 4 * 1024
+
+// maxFields 文件之前的普通表单字段允许的最大数量
+const maxFields = 32
+
+// multipartOverhead multipart请求体中分隔符、分段头和普通字段允许占用的额外字节数
+const multipartOverhead = 64 * 1024
+
+// 错误定义
+var (
+	errMalformedUpload = stderrors.New("malformed multipart upload")
+	errUploadTooLarge  = stderrors.New("upload exceeds maximum file size")
+)
+
+// nextFilePart 读取文件之前的普通字段，返回名为file的文件分段和这些字段的值，
+// 文件之后的字段不会被读取；请求体不是有效的multipart或没有file字段时返回errMalformedUpload
+func nextFilePart(mr *multipart.Reader) (*multipart.Part, map[string]string, error) {
+	fields := make(map[string]string)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, nil, errMalformedUpload
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			return part, fields, nil
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+		part.Close()
+		if err != nil || len(value) > maxFieldSize || len(fields) >= maxFields {
+			return nil, nil, errMalformedUpload
+		}
+		fields[part.FormName()] = string(value)
+	}
+}
+
+// uploadLimitReader 统计读取的字节数，超过limit时返回errUploadTooLarge终止写入；
+// 同时记录读取请求体时发生的错误，用于区分请求体不完整和存储写入失败
+type uploadLimitReader struct {
+	r        io.Reader
+	n        int64
+	limit    int64
+	exceeded bool
+	readErr  error
+}
+
+// Read 读取数据
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		l.exceeded = true
+		return n, errUploadTooLarge
+	}
+	if err != nil && err != io.EOF {
+		l.readErr = err
+	}
+	return n, err
+}
@@ -1,58 +1,245 @@
 package config
 
+import (
+	"os"
+	"strings"
+)
+
 // Config 应用配置
 type Config struct {
 	Server    ServerConfig    `json:"server"`
 	Clipboard ClipboardConfig `json:"clipboard"`
 	File      FileConfig      `json:"file"`
+	Events    EventConfig     `json:"events"`
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	Auth      AuthConfig      `json:"auth"`
+	Room      RoomConfig      `json:"room"`
+	Share     ShareConfig     `json:"share"`
 }
 
-// ServerConfig 服务器配置
+// ServerConfig 服务器配置，PublicURL为客户端访问服务的地址（例如https://clip.example.com），
+// 用于生成分享链接和房间二维码，为空时根据请求推断
 type ServerConfig struct {
-	Port string `json:"port"`
-	Host string `json:"host"`
+	Port           string   `json:"port"`
+	Host           string   `json:"host"`
+	PublicURL      string   `json:"publicUrl"`
+	TrustedProxies []string `json:"trustedProxies"`
 }
 
-// ClipboardConfig 字符串剪切板配置
+// ClipboardConfig 字符串剪切板配置，每个命名空间拥有独立的剪切板，MaxMemory和MaxItems为单个命名空间的限制
 type ClipboardConfig struct {
-	MaxMemory   int64 `json:"maxMemory"`
-	MaxItems    int   `json:"maxItems"`
-	MaxItemSize int64 `json:"maxItemSize"`
+	MaxMemory           int64  `json:"maxMemory"`
+	MaxItems            int    `json:"maxItems"`
+	MaxItemSize         int64  `json:"maxItemSize"`
+	MaxExpiresIn        int64  `json:"maxExpiresIn"`
+	ExpireCheckInterval int64  `json:"expireCheckInterval"`
+	MaxWaitTimeout      int64  `json:"maxWaitTimeout"`
+	Store               string `json:"store"`
+	BoltPath            string `json:"boltPath"`
+	Persist             bool   `json:"persist"`
+	DataDir             string `json:"dataDir"`
+	SnapshotInterval    int64  `json:"snapshotInterval"`
 }
 
 // FileConfig 文件配置
 type FileConfig struct {
-	UploadDir       string `json:"uploadDir"`
-	MetadataFile    string `json:"metadataFile"`
-	MaxFileSize     int64  `json:"maxFileSize"`
-	MaxStorage      int64  `json:"maxStorage"`
-	MaxDownloads    int    `json:"maxDownloads"`
-	SpeedLimit      int64  `json:"speedLimit"`
-	CleanupInterval int64  `json:"cleanupInterval"`
-	MaxAge          int64  `json:"maxAge"`
+	Storage         string   `json:"storage"`
+	S3              S3Config `json:"s3"`
+	UploadDir       string   `json:"uploadDir"`
+	MetadataStore   string   `json:"metadataStore"`
+	MetadataDB      string   `json:"metadataDB"`
+	MetadataFile    string   `json:"metadataFile"`
+	MetadataBackups int      `json:"metadataBackups"`
+	MaxFileSize     int64    `json:"maxFileSize"`
+	MaxStorage      int64    `json:"maxStorage"`
+	NamespaceQuota  int64    `json:"namespaceQuota"`
+	MaxDownloads    int      `json:"maxDownloads"`
+	DownloadCeiling int      `json:"downloadCeiling"`
+	RefundAborted   bool     `json:"refundAborted"`
+	ThumbnailSize   int      `json:"thumbnailSize"`
+	PreviewMaxSize  int64    `json:"previewMaxSize"`
+	PreviewSize     int      `json:"previewSize"`
+	AllowedTypes    []string `json:"allowedTypes"`
+	DeniedTypes     []string `json:"deniedTypes"`
+	CleanupInterval int64    `json:"cleanupInterval"`
+	ScrubInterval   int64    `json:"scrubInterval"`
+	MaxAge          int64    `json:"maxAge"`
+	MaxExpiresIn    int64    `json:"maxExpiresIn"`
+}
+
+// S3Config S3兼容对象存储配置
+type S3Config struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	Prefix    string `json:"prefix"`
+	PathStyle bool   `json:"pathStyle"`
+}
+
+// EventConfig 实时事件配置
+type EventConfig struct {
+	HistorySize       int   `json:"historySize"`
+	HeartbeatInterval int64 `json:"heartbeatInterval"`
+}
+
+// RateLimitConfig 带宽限速配置，速率单位为字节每秒，0表示不限速
+type RateLimitConfig struct {
+	Download    BandwidthConfig `json:"download"`
+	Upload      BandwidthConfig `json:"upload"`
+	IdleTimeout int64           `json:"idleTimeout"`
+}
+
+// BandwidthConfig 全局和单个客户端（IP或令牌）的带宽限制
+type BandwidthConfig struct {
+	GlobalRate  int64 `json:"globalRate"`
+	GlobalBurst int64 `json:"globalBurst"`
+	ClientRate  int64 `json:"clientRate"`
+	ClientBurst int64 `json:"clientBurst"`
+}
+
+// defaultDeniedTypes 默认禁止上传的文件类型（可执行文件）
+var defaultDeniedTypes = []string{
+	"application/vnd.microsoft.portable-executable",
+	"application/x-elf",
+	"application/x-mach-binary",
+}
+
+// AuthConfig 身份认证配置，AnonymousScopes为未携带令牌的请求拥有的权限，为空表示所有接口都需要令牌
+type AuthConfig struct {
+	TokenFile       string   `json:"tokenFile"`
+	AnonymousScopes []string `json:"anonymousScopes"`
+}
+
+// RoomConfig 房间配置，房间内的设备通过8位配对码加入并共用剪切板和文件，时间单位为毫秒
+type RoomConfig struct {
+	TTL           int64 `json:"ttl"`
+	MaxTTL        int64 `json:"maxTtl"`
+	MaxRooms      int   `json:"maxRooms"`
+	MaxMembers    int   `json:"maxMembers"`
+	JoinAttempts  int   `json:"joinAttempts"`
+	CodeAttempts  int   `json:"codeAttempts"`
+	CheckInterval int64 `json:"checkInterval"`
+}
+
+// ShareConfig 分享链接配置，Secret为HMAC签名密钥（为空时使用KeyFile中自动生成的密钥），
+// TTL为默认有效期，MaxTTL为可设置的最长有效期，MaxUses为可设置的最大使用次数（0表示允许不限次数），时间单位为毫秒
+type ShareConfig struct {
+	Secret        string `json:"secret"`
+	KeyFile       string `json:"keyFile"`
+	LinkFile      string `json:"linkFile"`
+	TTL           int64  `json:"ttl"`
+	MaxTTL        int64  `json:"maxTtl"`
+	MaxUses       int    `json:"maxUses"`
+	CheckInterval int64  `json:"checkInterval"`
 }
 
 // GetDefaultConfig 获取默认配置
 func GetDefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: "3000",
-			Host: "localhost",
+			Port:           "3000",
+			Host:           "localhost",
+			PublicURL:      os.Getenv("PUBLIC_URL"),
+			TrustedProxies: envList("TRUSTED_PROXIES"), // 默认不信任任何代理，客户端IP取连接的对端地址
 		},
 		Clipboard: ClipboardConfig{
-			MaxMemory:   1 * 1024 * 1024, // 1MB
-			MaxItems:    512,
-			MaxItemSize: 1 * 1024, // 1KB
+			MaxMemory:           1 * 1024 * 1024, // 1MB
+			MaxItems:            512,
+			MaxItemSize:         1 * 1024,                 // 1KB
+			MaxExpiresIn:        30 * 24 * 60 * 60 * 1000, // 30天
+			ExpireCheckInterval: 60 * 1000,                // 1分钟
+			MaxWaitTimeout:      60 * 1000,                // 1分钟
+			Store:               "memory",                 // memory 或 bolt
+			BoltPath:            "./data/clipboard.db",
+			Persist:             true,
+			DataDir:             "./data/clipboard",
+			SnapshotInterval:    5 * 60 * 1000, // 5分钟
 		},
 		File: FileConfig{
+			Storage: "local", // local 或 s3
+			S3: S3Config{
+				Endpoint:  "http://localhost:9000",
+				Region:    "us-east-1",
+				Bucket:    "cloud-clipboard",
+				AccessKey: os.Getenv("S3_ACCESS_KEY"),
+				SecretKey: os.Getenv("S3_SECRET_KEY"),
+				PathStyle: true, // MinIO需要使用路径风格
+			},
 			UploadDir:       "./uploads",
+			MetadataStore:   "bolt", // bolt 或 json
+			MetadataDB:      "./data/files.db",
 			MetadataFile:    "./data/files.json",
+			MetadataBackups: 5,
 			MaxFileSize:     16 * 1024 * 1024,  // 16MB
 			MaxStorage:      512 * 1024 * 1024, // 512GB
+			NamespaceQuota:  128 * 1024 * 1024, // 单个命名空间的文件大小之和，0表示只受maxStorage限制
 			MaxDownloads:    10,
-			SpeedLimit:      1 * 1024 * 1024,         // 1MB/s
-			CleanupInterval: 24 * 60 * 60 * 1000,     // 24小时
-			MaxAge:          7 * 24 * 60 * 60 * 1000, // 7天
+			DownloadCeiling: 100,   // 上传者可设置的最大下载次数，0表示不限制（允许设置为不限次数）
+			RefundAborted:   false, // 客户端中断的下载不退还次数，否则反复中断可以绕过次数限制
+			ThumbnailSize:   256,
+			PreviewMaxSize:  64 * 1024, // 64KB
+			PreviewSize:     1280,      // 图片预览长边的最大像素数，预览不返回原图
+			AllowedTypes:    nil,       // 为空时允许所有未被禁止的类型
+			DeniedTypes:     defaultDeniedTypes,
+			CleanupInterval: 24 * 60 * 60 * 1000,      // 24小时
+			ScrubInterval:   24 * 60 * 60 * 1000,      // 24小时
+			MaxAge:          7 * 24 * 60 * 60 * 1000,  // 7天
+			MaxExpiresIn:    30 * 24 * 60 * 60 * 1000, // 上传者可设置的最长有效期，30天
+		},
+		Events: EventConfig{
+			HistorySize:       1000,
+			HeartbeatInterval: 30 * 1000, // 30秒
+		},
+		RateLimit: RateLimitConfig{
+			Download: BandwidthConfig{
+				GlobalRate:  8 * 1024 * 1024, // 8MB/s
+				GlobalBurst: 512 * 1024,      // 512KB
+				ClientRate:  1 * 1024 * 1024, // 1MB/s
+				ClientBurst: 256 * 1024,      // 256KB
+			},
+			Upload: BandwidthConfig{
+				GlobalRate:  8 * 1024 * 1024, // 8MB/s
+				GlobalBurst: 512 * 1024,      // 512KB
+				ClientRate:  2 * 1024 * 1024, // 2MB/s
+				ClientBurst: 256 * 1024,      // 256KB
+			},
+			IdleTimeout: 10 * 60 * 1000, // 10分钟
+		},
+		Auth: AuthConfig{
+			TokenFile:       "./data/tokens.json",
+			AnonymousScopes: []string{"clipboard:read", "clipboard:write", "files:read", "files:write"}, // 清空剪切板和管理接口需要admin令牌
 		},
+		Room: RoomConfig{
+			TTL:           60 * 60 * 1000,      // 1小时
+			MaxTTL:        24 * 60 * 60 * 1000, // 24小时
+			MaxRooms:      1000,
+			MaxMembers:    16,
+			JoinAttempts:  10,        // 每个客户端每分钟允许的加入失败次数
+			CodeAttempts:  1000,      // 每个配对码允许的加入失败次数（所有客户端合计），用完后更换配对码
+			CheckInterval: 60 * 1000, // 1分钟
+		},
+		Share: ShareConfig{
+			Secret:        os.Getenv("SHARE_SECRET"), // 多个实例需要使用相同的密钥
+			KeyFile:       "./data/share.key",
+			LinkFile:      "./data/shares.json",
+			TTL:           24 * 60 * 60 * 1000,     // 24小时
+			MaxTTL:        7 * 24 * 60 * 60 * 1000, // 7天
+			MaxUses:       100,
+			CheckInterval: 60 * 60 * 1000, // 1小时
+		},
+	}
+}
+
+// envList 读取逗号分隔的环境变量，未设置时返回nil
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
 	}
+
+	return list
 }
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenStore 基于JSON文件的令牌存储，文件被其他进程（例如命令行工具）修改后自动重新加载
+type TokenStore struct {
+	path    string
+	tokens  map[string]*Token // 按摘要索引
+	modTime time.Time
+	mu      sync.Mutex
+}
+
+// NewTokenStore 创建令牌存储，文件不存在时视为没有令牌
+func NewTokenStore(path string) (*TokenStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create token directory: %w", err)
+	}
+
+	s := &TokenStore{path: path, tokens: make(map[string]*Token)}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Create 创建令牌，namespace为令牌绑定的命名空间（为空时使用名为"token-<ID>"的独立空间），
+// expiresIn为有效期（毫秒），0表示永不过期，返回只出现这一次的令牌明文
+func (s *TokenStore) Create(name, namespace string, scopes []string, expiresIn int64) (string, *Token, error) {
+	scopes, err := ValidateScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := ValidateNamespace(namespace); err != nil {
+		return "", nil, err
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	token := &Token{
+		ID:        uuid.New().String()[:8],
+		Name:      strings.TrimSpace(name),
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		Namespace: namespace,
+		CreatedAt: now,
+	}
+	if token.Namespace == "" {
+		token.Namespace = ownNamespacePrefix + token.ID
+	}
+	if expiresIn > 0 {
+		token.ExpiresAt = now + expiresIn
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return "", nil, err
+	}
+	s.tokens[token.Hash] = token
+	if err := s.save(); err != nil {
+		delete(s.tokens, token.Hash)
+		return "", nil, err
+	}
+
+	return secret, token, nil
+}
+
+// Revoke 吊销令牌
+func (s *TokenStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	for hash, token := range s.tokens {
+		if token.ID == id {
+			delete(s.tokens, hash)
+			return s.save()
+		}
+	}
+
+	return ErrTokenNotFound
+}
+
+// List 获取所有令牌（按创建时间排序）
+func (s *TokenStore) List() ([]*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]*Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		copied := *token
+		tokens = append(tokens, &copied)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt < tokens[j].CreatedAt
+	})
+
+	return tokens, nil
+}
+
+// Authenticate 根据令牌明文获取身份，令牌不存在或已过期时返回ErrInvalidToken；
+// 每次认证都会检查文件是否被其他进程修改，通过命令行创建或吊销的令牌立即生效；
+// 最后使用时间只记录在内存中，随下一次写入一起保存
+func (s *TokenStore) Authenticate(secret string) (*Identity, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+	hash := hashSecret(secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	token, ok := s.tokens[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now().UnixMilli()
+	if token.ExpiresAt > 0 && now >= token.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+	token.LastUsedAt = now
+
+	identity := &Identity{
+		TokenID:   token.ID,
+		Name:      token.Name,
+		Scopes:    append([]string(nil), token.Scopes...),
+		Namespace: token.NamespaceName(),
+	}
+	if identity.Namespace == PublicNamespace {
+		identity.Namespace = ""
+	}
+
+	return identity, nil
+}
+
+// reload 文件修改时间变化时重新读取，调用方需持有锁
+func (s *TokenStore) reload() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.tokens = make(map[string]*Token)
+		s.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat token file: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	var list []*Token
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse token file: %w", err)
+	}
+
+	tokens := make(map[string]*Token, len(list))
+	for _, token := range list {
+		// 保留内存中记录的最后使用时间
+		if old, ok := s.tokens[token.Hash]; ok && old.LastUsedAt > token.LastUsedAt {
+			token.LastUsedAt = old.LastUsedAt
+		}
+		tokens[token.Hash] = token
+	}
+	s.tokens = tokens
+	s.modTime = info.ModTime()
+
+	return nil
+}
+
+// save 原子地写入令牌文件，调用方需持有锁
+func (s *TokenStore) save() error {
+	list := make([]*Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		list = append(list, token)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt < list[j].CreatedAt
+	})
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tokens: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close token file: %w", err)
+	}
+	// 令牌文件只允许服务进程读取
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("failed to chmod token file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to rename token file: %w", err)
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat token file: %w", err)
+	}
+	s.modTime = info.ModTime()
+
+	return nil
+}
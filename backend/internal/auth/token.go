@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 权限范围
+const (
+	ScopeClipboardRead  = "clipboard:read"
+	ScopeClipboardWrite = "clipboard:write"
+	ScopeFilesRead      = "files:read"
+	ScopeFilesWrite     = "files:write"
+	// ScopeAdmin 管理权限，包含所有其他权限
+	ScopeAdmin = "admin"
+)
+
+// Scopes 所有可用的权限范围
+var Scopes = []string{ScopeClipboardRead, ScopeClipboardWrite, ScopeFilesRead, ScopeFilesWrite, ScopeAdmin}
+
+// PublicNamespace 公共命名空间的名称，匿名请求和绑定到该名称的令牌共用同一个剪切板和文件空间；
+// 公共命名空间在身份和文件元数据中记为空字符串，兼容引入命名空间之前的数据
+const PublicNamespace = "public"
+
+// namespacePattern 命名空间名称只允许字母、数字、下划线和连字符，名称会用作数据目录名
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ownNamespacePrefix 令牌独立空间的名称前缀，创建令牌时不允许指定以此开头的名称，
+// 其他令牌无法加入某个令牌的独立空间
+const ownNamespacePrefix = "token-"
+
+// legacyNamespacePattern 旧版本令牌的独立空间直接以令牌ID（8位十六进制）命名，这类名称同样保留
+var legacyNamespacePattern = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+// tokenPrefix 令牌前缀，便于在配置和日志中识别泄露的令牌
+const tokenPrefix = "cct_"
+
+// Token API令牌，只保存令牌的SHA-256摘要，明文只在创建时返回一次
+type Token struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Hash       string   `json:"hash"`
+	Scopes     []string `json:"scopes"`
+	Namespace  string   `json:"namespace,omitempty"`
+	CreatedAt  int64    `json:"createdAt"`
+	ExpiresAt  int64    `json:"expiresAt,omitempty"`
+	LastUsedAt int64    `json:"lastUsedAt,omitempty"`
+}
+
+// NamespaceName 令牌绑定的命名空间名称，新创建的令牌总是记录命名空间（独立空间为"token-<ID>"），
+// 为空的只有旧版本创建的令牌，其独立空间以令牌ID命名
+func (t *Token) NamespaceName() string {
+	if t.Namespace == "" {
+		return t.ID
+	}
+	return t.Namespace
+}
+
+// Identity 请求的身份，匿名请求的TokenID为空，Namespace为空表示公共命名空间
+type Identity struct {
+	TokenID   string
+	Name      string
+	Scopes    []string
+	Namespace string
+}
+
+// Anonymous 未携带令牌的请求身份，使用公共命名空间
+func Anonymous(scopes []string) *Identity {
+	return &Identity{Name: "anonymous", Scopes: scopes}
+}
+
+// IsAnonymous 是否为匿名身份
+func (i *Identity) IsAnonymous() bool {
+	return i.TokenID == ""
+}
+
+// HasScope 是否拥有scopes中的任一权限，拥有admin权限时总是返回true
+func (i *Identity) HasScope(scopes ...string) bool {
+	for _, have := range i.Scopes {
+		if have == ScopeAdmin {
+			return true
+		}
+		for _, want := range scopes {
+			if have == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// String 用于日志的身份描述
+func (i *Identity) String() string {
+	if i.IsAnonymous() {
+		return i.Name
+	}
+	return fmt.Sprintf("%s(%s)", i.Name, i.TokenID)
+}
+
+// NamespaceName 用于显示的命名空间名称
+func (i *Identity) NamespaceName() string {
+	if i.Namespace == "" {
+		return PublicNamespace
+	}
+	return i.Namespace
+}
+
+// ValidateNamespace 检查创建令牌时指定的命名空间名称是否有效，空字符串表示使用令牌的独立空间；
+// 令牌独立空间使用的名称（"token-"开头或8位十六进制）是保留的，不能被其他令牌指定
+func ValidateNamespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("%w: %s", ErrInvalidNamespace, namespace)
+	}
+	if strings.HasPrefix(namespace, ownNamespacePrefix) || legacyNamespacePattern.MatchString(namespace) {
+		return fmt.Errorf("%w: %s is reserved", ErrInvalidNamespace, namespace)
+	}
+	return nil
+}
+
+// ResolveNamespace 将管理员指定的命名空间名称（与NamespaceName显示的名称一致）转换为请求身份中使用的名称，
+// "public"表示公共命名空间；与ValidateNamespace不同，令牌独立空间的保留名称也是有效的
+func ResolveNamespace(name string) (string, error) {
+	if name == PublicNamespace {
+		return "", nil
+	}
+	if !namespacePattern.MatchString(name) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidNamespace, name)
+	}
+	return name, nil
+}
+
+// ValidateScopes 检查权限范围是否有效并去重
+func ValidateScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !validScope(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+
+	if len(result) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	return result, nil
+}
+
+// validScope 是否为已知的权限范围
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSecret 生成令牌明文
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashSecret 计算令牌明文的摘要，令牌是高熵随机值，无需使用慢哈希
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// 错误定义
+var (
+	ErrTokenNotFound    = errors.New("token not found")
+	ErrInvalidToken     = errors.New("invalid or expired token")
+	ErrInvalidScope     = errors.New("invalid scope")
+	ErrInvalidNamespace = errors.New("invalid namespace")
+)
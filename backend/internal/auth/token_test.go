@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	tests := []struct {
+		namespace string
+		valid     bool
+	}{
+		{"", true},
+		{"public", true},
+		{"team_a", true},
+		{"Team-42", true},
+		{"token-1a2b3c4d", false},
+		{"token-", false},
+		{"1a2b3c4d", false},
+		{"1A2B3C4D", true},
+		{"1a2b3c4d5", true},
+		{"room:abc", false},
+		{"../etc", false},
+		{"a b", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateNamespace(tt.namespace)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateNamespace(%q) = %v, want nil", tt.namespace, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidNamespace) {
+			t.Errorf("ValidateNamespace(%q) = %v, want ErrInvalidNamespace", tt.namespace, err)
+		}
+	}
+}
+
+func TestTokenNamespace(t *testing.T) {
+	store, err := NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+
+	_, own, err := store.Create("own", "", []string{ScopeClipboardRead}, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if want := "token-" + own.ID; own.NamespaceName() != want {
+		t.Fatalf("NamespaceName = %q, want %q", own.NamespaceName(), want)
+	}
+
+	// 其他令牌不能指定某个令牌的独立空间
+	for _, namespace := range []string{own.NamespaceName(), own.ID} {
+		if _, _, err := store.Create("intruder", namespace, []string{ScopeClipboardRead}, 0); !errors.Is(err, ErrInvalidNamespace) {
+			t.Fatalf("Create with namespace %q: err = %v, want ErrInvalidNamespace", namespace, err)
+		}
+	}
+
+	_, shared, err := store.Create("shared", "team", []string{ScopeClipboardRead}, 0)
+	if err != nil {
+		t.Fatalf("Create shared: %v", err)
+	}
+	if shared.NamespaceName() != "team" {
+		t.Fatalf("NamespaceName = %q, want team", shared.NamespaceName())
+	}
+
+	// 旧版本创建的令牌没有记录命名空间，仍然使用以令牌ID命名的空间
+	legacy := &Token{ID: "0badc0de"}
+	if legacy.NamespaceName() != "0badc0de" {
+		t.Fatalf("legacy NamespaceName = %q, want 0badc0de", legacy.NamespaceName())
+	}
+}
+
+func TestResolveNamespace(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"public", "", true},
+		{"team_a", "team_a", true},
+		{"token-1a2b3c4d", "token-1a2b3c4d", true},
+		{"1a2b3c4d", "1a2b3c4d", true},
+		{"", "", false},
+		{"room:abc", "", false},
+		{"../etc", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := ResolveNamespace(tt.name)
+		if tt.ok && (err != nil || got != tt.want) {
+			t.Errorf("ResolveNamespace(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidNamespace) {
+			t.Errorf("ResolveNamespace(%q) = %v, want ErrInvalidNamespace", tt.name, err)
+		}
+	}
+}
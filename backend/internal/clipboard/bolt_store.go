@@ -0,0 +1,549 @@
+package clipboard
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"cloud-clipboard/internal/logger"
+)
+
+var (
+	// itemsBucket 键 -> 缓存记录
+	itemsBucket = []byte("items")
+	// lruBucket 访问序号（大端序） -> 键，按键遍历即为LRU顺序
+	lruBucket = []byte("lru")
+)
+
+var _ Store = (*BoltStore)(nil)
+
+// BoltStore 基于bbolt的磁盘剪切板存储，淘汰策略与LRUCache一致
+type BoltStore struct {
+	db          *bolt.DB
+	maxSize     int64
+	maxItems    int
+	currentSize int64
+	count       int
+	access      uint64
+	seq         uint64
+	notify      notifier
+	mu          sync.Mutex
+}
+
+// boltRecord 磁盘上的缓存记录
+type boltRecord struct {
+	Value     string `json:"value"`
+	Size      int64  `json:"size"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+	ReadOnce  bool   `json:"readOnce,omitempty"`
+	Seq       uint64 `json:"seq"`
+	Access    uint64 `json:"access"`
+}
+
+// NewBoltStore 打开或创建基于bbolt的剪切板存储
+func NewBoltStore(path string, maxSize int64, maxItems int) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create clipboard data directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open clipboard database: %w", err)
+	}
+
+	s := &BoltStore{
+		db:       db,
+		maxSize:  maxSize,
+		maxItems: maxItems,
+	}
+
+	// 从已有数据中恢复统计信息和序号
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := createBuckets(tx); err != nil {
+			return err
+		}
+
+		return tx.Bucket(itemsBucket).ForEach(func(k, v []byte) error {
+			rec, err := decodeRecord(v)
+			if err != nil {
+				return err
+			}
+			s.currentSize += rec.Size
+			s.count++
+			if rec.Seq > s.seq {
+				s.seq = rec.Seq
+			}
+			if rec.Access > s.access {
+				s.access = rec.Access
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load clipboard database: %w", err)
+	}
+
+	return s, nil
+}
+
+// Put 添加或更新缓存项
+func (s *BoltStore) Put(key, value string) error {
+	return s.PutWithOptions(key, value, ItemOptions{})
+}
+
+// PutWithOptions 按指定选项添加或更新缓存项
+func (s *BoltStore) PutWithOptions(key, value string, opts ItemOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := int64(len([]byte(value)))
+
+	// 检查单条数据大小限制
+	if size > s.maxSize {
+		return ErrItemSizeExceeded
+	}
+
+	currentSize, count := s.currentSize, s.count
+	access, seq := s.access+1, s.seq+1
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		items, lru := tx.Bucket(itemsBucket), tx.Bucket(lruBucket)
+
+		// 如果已存在该键，先移除旧记录
+		if old, err := getRecord(items, key); err != nil {
+			return err
+		} else if old != nil {
+			if err := lru.Delete(accessKey(old.Access)); err != nil {
+				return err
+			}
+			currentSize -= old.Size
+			count--
+		} else if count >= s.maxItems {
+			// 检查是否超过最大数量
+			evicted, err := evictOldest(items, lru)
+			if err != nil {
+				return err
+			}
+			if evicted != nil {
+				currentSize -= evicted.Size
+				count--
+			}
+		}
+
+		// 检查是否超过最大大小
+		for currentSize+size > s.maxSize {
+			evicted, err := evictOldest(items, lru)
+			if err != nil {
+				return err
+			}
+			if evicted == nil {
+				break
+			}
+			currentSize -= evicted.Size
+			count--
+		}
+
+		rec := &boltRecord{
+			Value:     value,
+			Size:      size,
+			ExpiresAt: opts.ExpiresAt,
+			ReadOnce:  opts.ReadOnce,
+			Seq:       seq,
+			Access:    access,
+		}
+		if err := putRecord(items, lru, key, rec); err != nil {
+			return err
+		}
+		currentSize += size
+		count++
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write clipboard item: %w", err)
+	}
+
+	s.currentSize, s.count = currentSize, count
+	s.access, s.seq = access, seq
+	s.notify.broadcast()
+
+	return nil
+}
+
+// Get 获取缓存项，已过期的缓存项视为不存在，阅后即焚的缓存项在返回的同时被删除
+func (s *BoltStore) Get(key string) (*CacheItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var item *CacheItem
+	var removed int64 = -1
+	access := s.access + 1
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		items, lru := tx.Bucket(itemsBucket), tx.Bucket(lruBucket)
+
+		rec, err := getRecord(items, key)
+		if err != nil || rec == nil {
+			return err
+		}
+
+		// 已过期但尚未被清理的缓存项直接回收
+		if rec.expired(time.Now().UnixMilli()) {
+			removed = rec.Size
+			return deleteRecord(items, lru, key, rec)
+		}
+
+		item = rec.item(key)
+
+		// 阅后即焚：在同一事务内读取并删除，保证只能被读取一次
+		if rec.ReadOnce {
+			removed = rec.Size
+			return deleteRecord(items, lru, key, rec)
+		}
+
+		// 移动到LRU头部
+		if err := lru.Delete(accessKey(rec.Access)); err != nil {
+			return err
+		}
+		rec.Access = access
+		return putRecord(items, lru, key, rec)
+	})
+	if err != nil {
+		logger.Errorf("Failed to read clipboard item: %v", err)
+		return nil, false
+	}
+
+	if removed >= 0 {
+		s.currentSize -= removed
+		s.count--
+	}
+	if item == nil {
+		return nil, false
+	}
+	if !item.ReadOnce {
+		s.access = access
+	}
+
+	return item, true
+}
+
+// WaitNext 等待在afterKey之后写入的缓存项，afterKey为空或不存在时等待下一次写入，
+// ctx结束前没有新内容则返回false。阅后即焚的缓存项不包含内容
+func (s *BoltStore) WaitNext(ctx context.Context, afterKey string) (*CacheItem, bool) {
+	s.mu.Lock()
+
+	afterSeq := s.seq
+	s.db.View(func(tx *bolt.Tx) error {
+		if rec, err := getRecord(tx.Bucket(itemsBucket), afterKey); err == nil && rec != nil {
+			afterSeq = rec.Seq
+		}
+		return nil
+	})
+
+	for {
+		if item := s.nextAfter(afterSeq); item != nil {
+			s.mu.Unlock()
+			return item, true
+		}
+
+		wait := s.notify.wait()
+		s.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, false
+		}
+
+		s.mu.Lock()
+	}
+}
+
+// nextAfter 在已持有锁的情况下查找序号大于afterSeq的最早写入的未过期缓存项
+func (s *BoltStore) nextAfter(afterSeq uint64) *CacheItem {
+	now := time.Now().UnixMilli()
+	var next *CacheItem
+	var nextSeq uint64
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(itemsBucket).ForEach(func(k, v []byte) error {
+			rec, err := decodeRecord(v)
+			if err != nil {
+				return err
+			}
+			if rec.Seq > afterSeq && !rec.expired(now) && (next == nil || rec.Seq < nextSeq) {
+				next, nextSeq = rec.item(string(k)), rec.Seq
+				if rec.ReadOnce {
+					next.Value = ""
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		logger.Errorf("Failed to scan clipboard items: %v", err)
+		return nil
+	}
+
+	return next
+}
+
+// Delete 删除缓存项
+func (s *BoltStore) Delete(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64 = -1
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		items, lru := tx.Bucket(itemsBucket), tx.Bucket(lruBucket)
+
+		rec, err := getRecord(items, key)
+		if err != nil || rec == nil {
+			return err
+		}
+
+		removed = rec.Size
+		return deleteRecord(items, lru, key, rec)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete clipboard item: %w", err)
+	}
+	if removed < 0 {
+		return false, nil
+	}
+
+	s.currentSize -= removed
+	s.count--
+	return true, nil
+}
+
+// RemoveExpired 清理所有已过期的缓存项，返回清理数量
+func (s *BoltStore) RemoveExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	var removedCount int
+	var removedSize int64
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		items, lru := tx.Bucket(itemsBucket), tx.Bucket(lruBucket)
+
+		// 先收集再删除，避免在遍历时修改bucket
+		expired := make(map[string]*boltRecord)
+		err := items.ForEach(func(k, v []byte) error {
+			rec, err := decodeRecord(v)
+			if err != nil {
+				return err
+			}
+			if rec.expired(now) {
+				expired[string(k)] = rec
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for key, rec := range expired {
+			if err := deleteRecord(items, lru, key, rec); err != nil {
+				return err
+			}
+			removedCount++
+			removedSize += rec.Size
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("Failed to remove expired clipboard items: %v", err)
+		return 0
+	}
+
+	s.currentSize -= removedSize
+	s.count -= removedCount
+	return removedCount
+}
+
+// GetAll 获取所有未过期的缓存项（按最近访问排序），阅后即焚的缓存项不包含内容
+func (s *BoltStore) GetAll() []*CacheItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	result := make([]*CacheItem, 0, s.count)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		items := tx.Bucket(itemsBucket)
+		cursor := tx.Bucket(lruBucket).Cursor()
+
+		// 访问序号越大越新，逆序遍历
+		for k, key := cursor.Last(); k != nil; k, key = cursor.Prev() {
+			rec, err := getRecord(items, string(key))
+			if err != nil {
+				return err
+			}
+			if rec == nil || rec.expired(now) {
+				continue
+			}
+
+			item := rec.item(string(key))
+			if rec.ReadOnce {
+				item.Value = ""
+			}
+			result = append(result, item)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("Failed to list clipboard items: %v", err)
+	}
+
+	return result
+}
+
+// GetSize 获取当前缓存大小
+func (s *BoltStore) GetSize() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.currentSize
+}
+
+// GetCount 获取当前缓存项数量
+func (s *BoltStore) GetCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.count
+}
+
+// Clear 清空所有缓存项
+func (s *BoltStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(itemsBucket); err != nil {
+			return err
+		}
+		if err := tx.DeleteBucket(lruBucket); err != nil {
+			return err
+		}
+		return createBuckets(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear clipboard items: %w", err)
+	}
+
+	s.currentSize = 0
+	s.count = 0
+	return nil
+}
+
+// Close 关闭数据库
+func (s *BoltStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Close()
+}
+
+// expired 判断记录是否已过期
+func (r *boltRecord) expired(now int64) bool {
+	return r.ExpiresAt > 0 && r.ExpiresAt <= now
+}
+
+// item 将记录转换为缓存项
+func (r *boltRecord) item(key string) *CacheItem {
+	return &CacheItem{
+		Key:       key,
+		Value:     r.Value,
+		Size:      r.Size,
+		ExpiresAt: r.ExpiresAt,
+		ReadOnce:  r.ReadOnce,
+	}
+}
+
+// createBuckets 创建所需的bucket
+func createBuckets(tx *bolt.Tx) error {
+	if _, err := tx.CreateBucketIfNotExists(itemsBucket); err != nil {
+		return err
+	}
+	_, err := tx.CreateBucketIfNotExists(lruBucket)
+	return err
+}
+
+// accessKey 将访问序号编码为可排序的键
+func accessKey(access uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, access)
+	return key
+}
+
+// decodeRecord 解码缓存记录
+func decodeRecord(data []byte) (*boltRecord, error) {
+	var rec boltRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal clipboard record: %w", err)
+	}
+	return &rec, nil
+}
+
+// getRecord 读取缓存记录，不存在时返回nil
+func getRecord(items *bolt.Bucket, key string) (*boltRecord, error) {
+	data := items.Get([]byte(key))
+	if data == nil {
+		return nil, nil
+	}
+	return decodeRecord(data)
+}
+
+// putRecord 写入缓存记录及其LRU索引
+func putRecord(items, lru *bolt.Bucket, key string, rec *boltRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal clipboard record: %w", err)
+	}
+	if err := items.Put([]byte(key), data); err != nil {
+		return err
+	}
+	return lru.Put(accessKey(rec.Access), []byte(key))
+}
+
+// deleteRecord 删除缓存记录及其LRU索引
+func deleteRecord(items, lru *bolt.Bucket, key string, rec *boltRecord) error {
+	if err := lru.Delete(accessKey(rec.Access)); err != nil {
+		return err
+	}
+	return items.Delete([]byte(key))
+}
+
+// evictOldest 淘汰最久未访问的记录，没有可淘汰的记录时返回nil
+func evictOldest(items, lru *bolt.Bucket) (*boltRecord, error) {
+	cursor := lru.Cursor()
+	for k, key := cursor.First(); k != nil; k, key = cursor.First() {
+		rec, err := getRecord(items, string(key))
+		if err != nil {
+			return nil, err
+		}
+		if rec == nil {
+			// 索引残留，直接清理
+			if err := lru.Delete(k); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return rec, deleteRecord(items, lru, string(key), rec)
+	}
+
+	return nil, nil
+}
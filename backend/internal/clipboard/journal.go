@@ -0,0 +1,224 @@
+package clipboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"cloud-clipboard/internal/logger"
+)
+
+const (
+	snapshotFileName = "snapshot.json"
+	journalFileName  = "journal.log"
+)
+
+// 日志操作类型
+const (
+	opPut    = "put"
+	opTouch  = "touch"
+	opDelete = "delete"
+	opClear  = "clear"
+)
+
+// journalEntry 追加日志条目
+type journalEntry struct {
+	Seq       uint64 `json:"seq"`
+	Op        string `json:"op"`
+	Key       string `json:"key,omitempty"`
+	Value     string `json:"value,omitempty"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+	ReadOnce  bool   `json:"readOnce,omitempty"`
+}
+
+// snapshot 缓存快照，Items按最近访问排序（最新的在前）
+type snapshot struct {
+	Seq   uint64       `json:"seq"`
+	Items []*CacheItem `json:"items"`
+}
+
+// journal 剪切板持久化日志（快照 + 追加日志）
+type journal struct {
+	snapshotPath string
+	file         *os.File
+	seq          uint64
+	mu           sync.Mutex
+}
+
+// openJournal 打开持久化目录下的快照和追加日志
+func openJournal(dataDir string) (*journal, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create clipboard data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dataDir, journalFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open clipboard journal: %w", err)
+	}
+
+	return &journal{
+		snapshotPath: filepath.Join(dataDir, snapshotFileName),
+		file:         file,
+	}, nil
+}
+
+// append 追加一条日志，写操作会立即落盘
+func (j *journal) append(entry journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq++
+	entry.Seq = j.seq
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	if _, err := j.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write clipboard journal: %w", err)
+	}
+
+	// 访问记录只影响顺序，不强制刷盘
+	if entry.Op != opTouch {
+		if err := j.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync clipboard journal: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// restore 将快照和日志回放到缓存中
+func (j *journal) restore(c *LRUCache) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	snap, err := j.readSnapshot()
+	if err != nil {
+		return err
+	}
+
+	// 快照按最近访问排序，逆序写入即可还原LRU顺序
+	for i := len(snap.Items) - 1; i >= 0; i-- {
+		item := snap.Items[i]
+		c.put(item.Key, item.Value, int64(len([]byte(item.Value))), ItemOptions{ExpiresAt: item.ExpiresAt, ReadOnce: item.ReadOnce})
+	}
+	j.seq = snap.Seq
+
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek clipboard journal: %w", err)
+	}
+
+	scanner := bufio.NewScanner(j.file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// 崩溃时最后一行可能只写了一半，丢弃其后的内容
+			logger.Warnf("Discarding corrupt clipboard journal tail after seq %d: %v", j.seq, err)
+			break
+		}
+
+		// 已包含在快照中的日志跳过
+		if entry.Seq <= snap.Seq {
+			continue
+		}
+
+		j.apply(c, entry)
+		j.seq = entry.Seq
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read clipboard journal: %w", err)
+	}
+
+	return nil
+}
+
+// apply 回放单条日志
+func (j *journal) apply(c *LRUCache, entry journalEntry) {
+	switch entry.Op {
+	case opPut:
+		c.put(entry.Key, entry.Value, int64(len([]byte(entry.Value))), ItemOptions{ExpiresAt: entry.ExpiresAt, ReadOnce: entry.ReadOnce})
+	case opTouch:
+		if n, ok := c.cache[entry.Key]; ok {
+			c.moveToHead(n)
+		}
+	case opDelete:
+		if n, ok := c.cache[entry.Key]; ok {
+			c.removeNode(n)
+		}
+	case opClear:
+		c.clear()
+	}
+}
+
+// compact 将当前缓存内容写入快照并截断追加日志
+func (j *journal) compact(items []*CacheItem) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	data, err := json.Marshal(&snapshot{Seq: j.seq, Items: items})
+	if err != nil {
+		return fmt.Errorf("failed to marshal clipboard snapshot: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免快照写到一半
+	tmpPath := j.snapshotPath + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create clipboard snapshot: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write clipboard snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync clipboard snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close clipboard snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, j.snapshotPath); err != nil {
+		return fmt.Errorf("failed to replace clipboard snapshot: %w", err)
+	}
+
+	// 快照记录了序号，即使截断前崩溃，旧日志也会在回放时被跳过
+	if err := j.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate clipboard journal: %w", err)
+	}
+
+	return nil
+}
+
+// close 关闭日志文件
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
+
+// readSnapshot 读取快照，不存在时返回空快照
+func (j *journal) readSnapshot() (*snapshot, error) {
+	data, err := os.ReadFile(j.snapshotPath)
+	if os.IsNotExist(err) {
+		return &snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clipboard snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal clipboard snapshot: %w", err)
+	}
+
+	return &snap, nil
+}
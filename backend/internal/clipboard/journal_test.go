@@ -0,0 +1,156 @@
+package clipboard
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// journalLines 返回追加日志中的行数
+func journalLines(t *testing.T, dir string) int {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join(dir, journalFileName))
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	defer f.Close()
+
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		lines++
+	}
+	return lines
+}
+
+func TestJournalReplay(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestCache(t, dir, 1024, 10)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := c.Put(key, "value-"+key); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+	if _, err := c.Delete("b"); err != nil {
+		t.Fatalf("Delete b: %v", err)
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get a: not found")
+	}
+	if err := c.PutWithOptions("d", "secret", ItemOptions{ReadOnce: true}); err != nil {
+		t.Fatalf("Put d: %v", err)
+	}
+	crash(t, c)
+
+	c = openTestCache(t, dir, 1024, 10)
+	if got, want := keys(c.GetAll()), []string{"d", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys after replay = %v, want %v", got, want)
+	}
+	item, ok := c.Get("c")
+	if !ok || item.Value != "value-c" {
+		t.Fatalf("Get c = %+v, %v", item, ok)
+	}
+	if item, ok := c.Get("d"); !ok || !item.ReadOnce || item.Value != "secret" {
+		t.Fatalf("Get d = %+v, %v", item, ok)
+	}
+}
+
+func TestJournalCompaction(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestCache(t, dir, 1024, 10)
+	for _, key := range []string{"a", "b"} {
+		if err := c.Put(key, key); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+	if err := c.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if n := journalLines(t, dir); n != 0 {
+		t.Fatalf("journal has %d lines after compaction, want 0", n)
+	}
+
+	if err := c.Put("c", "c"); err != nil {
+		t.Fatalf("Put c: %v", err)
+	}
+	if _, err := c.Delete("a"); err != nil {
+		t.Fatalf("Delete a: %v", err)
+	}
+	if n := journalLines(t, dir); n != 2 {
+		t.Fatalf("journal has %d lines, want 2", n)
+	}
+	crash(t, c)
+
+	// 快照加上压缩之后的日志
+	c = openTestCache(t, dir, 1024, 10)
+	if got, want := keys(c.GetAll()), []string{"c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys after restart = %v, want %v", got, want)
+	}
+	// 启动时已压缩
+	if n := journalLines(t, dir); n != 0 {
+		t.Fatalf("journal has %d lines after restart, want 0", n)
+	}
+}
+
+func TestJournalSkipsEntriesInSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	// 写入快照后、截断日志前崩溃：日志中仍有快照已包含的记录
+	snap := `{"seq":2,"items":[{"key":"b","value":"b","size":1},{"key":"a","value":"a","size":1}]}`
+	journal := `{"seq":1,"op":"put","key":"a","value":"a"}
+{"seq":2,"op":"delete","key":"a"}
+{"seq":3,"op":"put","key":"c","value":"c"}
+`
+	if err := os.WriteFile(filepath.Join(dir, snapshotFileName), []byte(snap), 0644); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, journalFileName), []byte(journal), 0644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	c := openTestCache(t, dir, 1024, 10)
+	if got, want := keys(c.GetAll()), []string{"c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+	if c.journal.seq != 3 {
+		t.Fatalf("journal seq = %d, want 3", c.journal.seq)
+	}
+}
+
+func TestJournalIgnoresCorruptTail(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestCache(t, dir, 1024, 10)
+	for _, key := range []string{"a", "b"} {
+		if err := c.Put(key, key); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+	crash(t, c)
+
+	// 崩溃时最后一条记录只写了一半
+	f, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	if _, err := f.WriteString(`{"seq":3,"op":"put","key":"c","val`); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	f.Close()
+
+	c = openTestCache(t, dir, 1024, 10)
+	if got, want := keys(c.GetAll()), []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+
+	// 损坏的尾部已被丢弃，之后写入的记录可以正常回放
+	if err := c.Put("d", "d"); err != nil {
+		t.Fatalf("Put d: %v", err)
+	}
+	crash(t, c)
+
+	c = openTestCache(t, dir, 1024, 10)
+	if got, want := keys(c.GetAll()), []string{"d", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys after second restart = %v, want %v", got, want)
+	}
+}
@@ -1,10 +1,16 @@
 package clipboard
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
+
+	"cloud-clipboard/internal/logger"
 )
 
+var _ Store = (*LRUCache)(nil)
+
 // LRUCache LRU缓存实现
 type LRUCache struct {
 	maxSize     int64
@@ -13,16 +19,30 @@ type LRUCache struct {
 	cache       map[string]*node
 	head        *node
 	tail        *node
+	journal     *journal
+	seq         uint64
+	notify      notifier
 	mu          sync.RWMutex
 }
 
 // node 双向链表节点
 type node struct {
-	key   string
-	value string
-	size  int64
-	prev  *node
-	next  *node
+	key       string
+	value     string
+	size      int64
+	expiresAt int64
+	readOnce  bool
+	seq       uint64
+	prev      *node
+	next      *node
+}
+
+// ItemOptions 缓存项选项
+type ItemOptions struct {
+	// ExpiresAt 过期时间（毫秒时间戳），0表示永不过期
+	ExpiresAt int64
+	// ReadOnce 阅后即焚，首次读取后立即删除
+	ReadOnce bool
 }
 
 // NewLRUCache 创建新的LRU缓存
@@ -34,8 +54,37 @@ func NewLRUCache(maxSize int64, maxItems int) *LRUCache {
 	}
 }
 
+// NewPersistentLRUCache 创建带持久化的LRU缓存，启动时从快照和追加日志恢复数据
+func NewPersistentLRUCache(maxSize int64, maxItems int, dataDir string) (*LRUCache, error) {
+	c := NewLRUCache(maxSize, maxItems)
+
+	j, err := openJournal(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := j.restore(c); err != nil {
+		j.close()
+		return nil, err
+	}
+	c.journal = j
+
+	// 启动时立即压缩一次，丢弃已回放的日志和可能损坏的尾部
+	if err := c.Compact(); err != nil {
+		j.close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
 // Put 添加或更新缓存项
 func (c *LRUCache) Put(key, value string) error {
+	return c.PutWithOptions(key, value, ItemOptions{})
+}
+
+// PutWithOptions 按指定选项添加或更新缓存项
+func (c *LRUCache) PutWithOptions(key, value string, opts ItemOptions) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -46,21 +95,75 @@ func (c *LRUCache) Put(key, value string) error {
 		return ErrItemSizeExceeded
 	}
 
+	// 淘汰的缓存项同样记录日志，否则重启后回放时可能淘汰不同的缓存项，已淘汰的内容重新出现
+	if err := c.evict(key, size); err != nil {
+		return err
+	}
+
+	// 先写日志再修改内存，保证磁盘上的记录不落后于内存
+	if c.journal != nil {
+		entry := journalEntry{Op: opPut, Key: key, Value: value, ExpiresAt: opts.ExpiresAt, ReadOnce: opts.ReadOnce}
+		if err := c.journal.append(entry); err != nil {
+			return err
+		}
+	}
+
+	c.put(key, value, size, opts)
+	return nil
+}
+
+// evict 在已持有锁的情况下按LRU顺序移除缓存项，为写入key腾出数量和大小，移除时记录日志
+func (c *LRUCache) evict(key string, size int64) error {
+	existing := c.cache[key]
+	for {
+		count, used := len(c.cache), c.currentSize+size
+		if existing != nil {
+			count--
+			used -= existing.size
+		}
+		if count < c.maxItems && used <= c.maxSize {
+			return nil
+		}
+
+		victim := c.tail
+		if victim == existing {
+			victim = victim.prev
+		}
+		if victim == nil {
+			return nil
+		}
+		if err := c.remove(victim); err != nil {
+			return err
+		}
+	}
+}
+
+// put 在已持有锁的情况下写入缓存项，回放日志时超出限制的部分按LRU顺序淘汰
+func (c *LRUCache) put(key, value string, size int64, opts ItemOptions) {
+	c.seq++
+	defer c.notify.broadcast()
+
 	// 如果缓存中已存在该键，更新值
 	if n, ok := c.cache[key]; ok {
 		c.currentSize -= n.size
 		n.value = value
 		n.size = size
+		n.expiresAt = opts.ExpiresAt
+		n.readOnce = opts.ReadOnce
+		n.seq = c.seq
 		c.currentSize += size
 		c.moveToHead(n)
-		return nil
+		return
 	}
 
 	// 创建新节点
 	newNode := &node{
-		key:   key,
-		value: value,
-		size:  size,
+		key:       key,
+		value:     value,
+		size:      size,
+		expiresAt: opts.ExpiresAt,
+		readOnce:  opts.ReadOnce,
+		seq:       c.seq,
 	}
 
 	// 检查是否超过最大数量
@@ -77,36 +180,145 @@ func (c *LRUCache) Put(key, value string) error {
 	c.cache[key] = newNode
 	c.currentSize += size
 	c.moveToHead(newNode)
-
-	return nil
 }
 
-// Get 获取缓存项
-func (c *LRUCache) Get(key string) (string, bool) {
+// Get 获取缓存项，已过期的缓存项视为不存在，阅后即焚的缓存项在返回的同时被删除
+func (c *LRUCache) Get(key string) (*CacheItem, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	n, ok := c.cache[key]
 	if !ok {
-		return "", false
+		return nil, false
+	}
+
+	// 已过期但尚未被清理的缓存项直接回收，重启后加载时同样会被过滤
+	if n.expired(time.Now().UnixMilli()) {
+		if err := c.remove(n); err != nil {
+			logger.Errorf("Failed to remove expired clipboard item: %v", err)
+		}
+		return nil, false
+	}
+
+	// 阅后即焚：在同一把锁内读取并删除，保证只能被读取一次；
+	// 删除无法写入日志时不返回内容，否则重启后内容会再次出现
+	if n.readOnce {
+		if err := c.remove(n); err != nil {
+			logger.Errorf("Failed to remove read-once clipboard item: %v", err)
+			return nil, false
+		}
+		return n.item(), true
+	}
+
+	// 访问会改变LRU顺序，同样需要记录
+	if c.journal != nil && n != c.head {
+		if err := c.journal.append(journalEntry{Op: opTouch, Key: key}); err != nil {
+			logger.Errorf("Failed to append clipboard journal: %v", err)
+		}
 	}
 
 	c.moveToHead(n)
-	return n.value, true
+	return n.item(), true
+}
+
+// WaitNext 等待在afterKey之后写入的缓存项，afterKey为空或不存在时等待下一次写入，
+// ctx结束前没有新内容则返回false。阅后即焚的缓存项不包含内容
+func (c *LRUCache) WaitNext(ctx context.Context, afterKey string) (*CacheItem, bool) {
+	c.mu.Lock()
+
+	afterSeq := c.seq
+	if n, ok := c.cache[afterKey]; ok {
+		afterSeq = n.seq
+	}
+
+	for {
+		if n := c.nextAfter(afterSeq); n != nil {
+			item := n.item()
+			if n.readOnce {
+				item.Value = ""
+			}
+			c.mu.Unlock()
+			return item, true
+		}
+
+		wait := c.notify.wait()
+		c.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, false
+		}
+
+		c.mu.Lock()
+	}
+}
+
+// nextAfter 在已持有锁的情况下查找序号大于afterSeq的最早写入的未过期节点
+func (c *LRUCache) nextAfter(afterSeq uint64) *node {
+	now := time.Now().UnixMilli()
+	var next *node
+	for _, n := range c.cache {
+		if n.seq > afterSeq && !n.expired(now) && (next == nil || n.seq < next.seq) {
+			next = n
+		}
+	}
+
+	return next
 }
 
 // Delete 删除缓存项
-func (c *LRUCache) Delete(key string) bool {
+func (c *LRUCache) Delete(key string) (bool, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	n, ok := c.cache[key]
 	if !ok {
-		return false
+		return false, nil
+	}
+
+	if err := c.remove(n); err != nil {
+		return false, err
 	}
+	return true, nil
+}
+
+// RemoveExpired 清理所有已过期的缓存项，返回清理数量
+func (c *LRUCache) RemoveExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	var removedCount int
+	for _, n := range c.cache {
+		if n.expired(now) {
+			if err := c.remove(n); err != nil {
+				logger.Errorf("Failed to remove expired clipboard item: %v", err)
+				continue
+			}
+			removedCount++
+		}
+	}
+
+	return removedCount
+}
+
+// remove 在已持有锁的情况下记录日志并移除节点，日志写入失败时不移除
+func (c *LRUCache) remove(n *node) error {
+	if c.journal != nil {
+		if err := c.journal.append(journalEntry{Op: opDelete, Key: n.key}); err != nil {
+			return err
+		}
+	}
+
+	c.removeNode(n)
+	return nil
+}
 
+// removeNode 从缓存和链表中移除节点
+func (c *LRUCache) removeNode(n *node) {
 	c.currentSize -= n.size
-	delete(c.cache, key)
+	delete(c.cache, n.key)
 
 	if n.prev != nil {
 		n.prev.next = n.next
@@ -120,23 +332,38 @@ func (c *LRUCache) Delete(key string) bool {
 	if n == c.tail {
 		c.tail = n.prev
 	}
-
-	return true
+	n.prev = nil
+	n.next = nil
 }
 
-// GetAll 获取所有缓存项（按最近访问排序）
+// GetAll 获取所有未过期的缓存项（按最近访问排序），阅后即焚的缓存项不包含内容
 func (c *LRUCache) GetAll() []*CacheItem {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	now := time.Now().UnixMilli()
+	items := make([]*CacheItem, 0, len(c.cache))
+	for current := c.head; current != nil; current = current.next {
+		if current.expired(now) {
+			continue
+		}
+
+		item := current.item()
+		if current.readOnce {
+			item.Value = ""
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// items 在已持有锁的情况下按最近访问顺序导出所有缓存项
+func (c *LRUCache) items() []*CacheItem {
 	items := make([]*CacheItem, 0, len(c.cache))
 	current := c.head
 	for current != nil {
-		items = append(items, &CacheItem{
-			Key:   current.key,
-			Value: current.value,
-			Size:  current.size,
-		})
+		items = append(items, current.item())
 		current = current.next
 	}
 
@@ -160,16 +387,58 @@ func (c *LRUCache) GetCount() int {
 }
 
 // Clear 清空所有缓存项
-func (c *LRUCache) Clear() {
+func (c *LRUCache) Clear() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.journal != nil {
+		if err := c.journal.append(journalEntry{Op: opClear}); err != nil {
+			return err
+		}
+	}
+
+	c.clear()
+	return nil
+}
+
+// clear 在已持有锁的情况下清空缓存
+func (c *LRUCache) clear() {
 	c.cache = make(map[string]*node)
 	c.currentSize = 0
 	c.head = nil
 	c.tail = nil
 }
 
+// Compact 将缓存写入快照并截断追加日志，未启用持久化时不做任何操作
+func (c *LRUCache) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return nil
+	}
+
+	return c.journal.compact(c.items())
+}
+
+// Close 压缩并关闭持久化日志
+func (c *LRUCache) Close() error {
+	if err := c.Compact(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal == nil {
+		return nil
+	}
+
+	err := c.journal.close()
+	c.journal = nil
+	return err
+}
+
 // moveToHead 将节点移到链表头部
 func (c *LRUCache) moveToHead(n *node) {
 	if n == c.head {
@@ -219,11 +488,29 @@ func (c *LRUCache) removeTail() {
 	}
 }
 
+// expired 判断节点是否已过期
+func (n *node) expired(now int64) bool {
+	return n.expiresAt > 0 && n.expiresAt <= now
+}
+
+// item 将节点转换为缓存项
+func (n *node) item() *CacheItem {
+	return &CacheItem{
+		Key:       n.key,
+		Value:     n.value,
+		Size:      n.size,
+		ExpiresAt: n.expiresAt,
+		ReadOnce:  n.readOnce,
+	}
+}
+
 // CacheItem 缓存项
 type CacheItem struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
-	Size  int64  `json:"size"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Size      int64  `json:"size"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+	ReadOnce  bool   `json:"readOnce,omitempty"`
 }
 
 // 错误定义
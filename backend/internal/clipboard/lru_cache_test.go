@@ -0,0 +1,247 @@
+package clipboard
+
+import (
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"cloud-clipboard/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+
+	os.Exit(m.Run())
+}
+
+// keys 按顺序返回缓存项的键
+func keys(items []*CacheItem) []string {
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.Key)
+	}
+	return result
+}
+
+// openTestCache 打开dir下的持久化缓存
+func openTestCache(t *testing.T, dir string, maxSize int64, maxItems int) *LRUCache {
+	t.Helper()
+
+	c, err := NewPersistentLRUCache(maxSize, maxItems, dir)
+	if err != nil {
+		t.Fatalf("NewPersistentLRUCache: %v", err)
+	}
+	t.Cleanup(func() {
+		if c.journal != nil {
+			c.journal.close()
+		}
+	})
+
+	return c
+}
+
+// crash 模拟进程崩溃：关闭日志文件但不压缩
+func crash(t *testing.T, c *LRUCache) {
+	t.Helper()
+
+	if err := c.journal.close(); err != nil {
+		t.Fatalf("close journal: %v", err)
+	}
+	c.journal = nil
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	c := NewLRUCache(10, 3)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := c.Put(key, "12"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	// 访问a后b成为最久未访问的缓存项
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get a: not found")
+	}
+	if err := c.Put("d", "12"); err != nil {
+		t.Fatalf("Put d: %v", err)
+	}
+	if got, want := keys(c.GetAll()), []string{"d", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys after count eviction = %v, want %v", got, want)
+	}
+
+	// 超出大小限制时淘汰多个缓存项
+	if err := c.Put("e", "12345678"); err != nil {
+		t.Fatalf("Put e: %v", err)
+	}
+	if got, want := keys(c.GetAll()), []string{"e", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys after size eviction = %v, want %v", got, want)
+	}
+	if c.GetSize() != 10 || c.GetCount() != 2 {
+		t.Fatalf("size = %d, count = %d, want 10, 2", c.GetSize(), c.GetCount())
+	}
+
+	// 更新已有的缓存项时不淘汰自身
+	if err := c.Put("e", "123456789"); err != nil {
+		t.Fatalf("update e: %v", err)
+	}
+	if got, want := keys(c.GetAll()), []string{"e"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys after update = %v, want %v", got, want)
+	}
+
+	if err := c.Put("f", strings.Repeat("x", 11)); err != ErrItemSizeExceeded {
+		t.Fatalf("Put oversized item: err = %v, want ErrItemSizeExceeded", err)
+	}
+}
+
+func TestLRUCacheEvictionSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestCache(t, dir, 1024, 2)
+	for _, key := range []string{"a", "b"} {
+		if err := c.Put(key, key); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get a: not found")
+	}
+	if err := c.Put("c", "c"); err != nil {
+		t.Fatalf("Put c: %v", err)
+	}
+	crash(t, c)
+
+	// 提高数量限制后回放不会再淘汰任何缓存项，已淘汰的b只能由日志中的删除记录排除
+	c = openTestCache(t, dir, 1024, 3)
+	if got, want := keys(c.GetAll()), []string{"c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys after restart = %v, want %v", got, want)
+	}
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestCache(t, dir, 1024, 10)
+	now := time.Now().UnixMilli()
+	if err := c.PutWithOptions("expired", "a", ItemOptions{ExpiresAt: now - 1}); err != nil {
+		t.Fatalf("Put expired: %v", err)
+	}
+	if err := c.PutWithOptions("stale", "b", ItemOptions{ExpiresAt: now - 1}); err != nil {
+		t.Fatalf("Put stale: %v", err)
+	}
+	if err := c.PutWithOptions("live", "c", ItemOptions{ExpiresAt: now + time.Hour.Milliseconds()}); err != nil {
+		t.Fatalf("Put live: %v", err)
+	}
+
+	// 已过期的缓存项在清理之前同样视为不存在
+	if got, want := keys(c.GetAll()), []string{"live"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetAll = %v, want %v", got, want)
+	}
+	if _, ok := c.Get("expired"); ok {
+		t.Fatal("Get returned an expired item")
+	}
+	if item, ok := c.Get("live"); !ok || item.ExpiresAt == 0 {
+		t.Fatalf("Get live = %+v, %v", item, ok)
+	}
+	if n := c.RemoveExpired(); n != 1 {
+		t.Fatalf("RemoveExpired = %d, want 1", n)
+	}
+	if c.GetCount() != 1 {
+		t.Fatalf("count = %d, want 1", c.GetCount())
+	}
+	crash(t, c)
+
+	// 清理记录在日志中，重启后不会重新出现
+	c = openTestCache(t, dir, 1024, 10)
+	if c.GetCount() != 1 {
+		t.Fatalf("count after restart = %d, want 1", c.GetCount())
+	}
+	if item, ok := c.Get("live"); !ok || item.ExpiresAt != now+time.Hour.Milliseconds() {
+		t.Fatalf("Get live after restart = %+v, %v", item, ok)
+	}
+}
+
+func TestLRUCacheReadOnce(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestCache(t, dir, 1024, 10)
+	if err := c.PutWithOptions("secret", "burn", ItemOptions{ReadOnce: true}); err != nil {
+		t.Fatalf("Put secret: %v", err)
+	}
+	if err := c.Put("plain", "keep"); err != nil {
+		t.Fatalf("Put plain: %v", err)
+	}
+
+	// 列表中不包含阅后即焚的内容，也不会触发删除
+	for _, item := range c.GetAll() {
+		if item.Key == "secret" && (item.Value != "" || !item.ReadOnce) {
+			t.Fatalf("GetAll exposed read-once item: %+v", item)
+		}
+	}
+
+	item, ok := c.Get("secret")
+	if !ok || item.Value != "burn" {
+		t.Fatalf("first Get = %+v, %v", item, ok)
+	}
+	if _, ok := c.Get("secret"); ok {
+		t.Fatal("read-once item returned twice")
+	}
+	crash(t, c)
+
+	// 删除记录在日志中，重启后不会重新出现
+	c = openTestCache(t, dir, 1024, 10)
+	if _, ok := c.Get("secret"); ok {
+		t.Fatal("read-once item returned after restart")
+	}
+	if got, want := keys(c.GetAll()), []string{"plain"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys after restart = %v, want %v", got, want)
+	}
+}
+
+func TestLRUCacheWaitNext(t *testing.T) {
+	c := NewLRUCache(1024, 10)
+
+	// 没有新内容时在ctx结束后返回
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if item, ok := c.WaitNext(ctx, ""); ok {
+		t.Fatalf("WaitNext returned %+v without a write", item)
+	}
+
+	// 等待中的请求在写入时被唤醒
+	done := make(chan *CacheItem, 1)
+	go func() {
+		item, _ := c.WaitNext(context.Background(), "")
+		done <- item
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := c.Put("a", "1"); err != nil {
+		t.Fatalf("Put a: %v", err)
+	}
+	select {
+	case item := <-done:
+		if item == nil || item.Key != "a" || item.Value != "1" {
+			t.Fatalf("WaitNext woke with %+v", item)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitNext was not woken by Put")
+	}
+
+	// afterKey之后已有内容时立即返回最早写入的一条，阅后即焚的内容不返回
+	if err := c.PutWithOptions("b", "2", ItemOptions{ReadOnce: true}); err != nil {
+		t.Fatalf("Put b: %v", err)
+	}
+	if err := c.Put("c", "3"); err != nil {
+		t.Fatalf("Put c: %v", err)
+	}
+	item, ok := c.WaitNext(context.Background(), "a")
+	if !ok || item.Key != "b" || item.Value != "" {
+		t.Fatalf("WaitNext after a = %+v, %v", item, ok)
+	}
+	if item, ok := c.WaitNext(context.Background(), "b"); !ok || item.Key != "c" {
+		t.Fatalf("WaitNext after b = %+v, %v", item, ok)
+	}
+}
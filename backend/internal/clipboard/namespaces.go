@@ -0,0 +1,120 @@
+package clipboard
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrNamespaceNotFound 命名空间没有已打开或已持久化的存储
+var ErrNamespaceNotFound = errors.New("namespace not found")
+
+// StoreFactory 为命名空间创建剪切板存储，namespace为空表示公共命名空间
+type StoreFactory func(namespace string) (Store, error)
+
+// StoreExists 判断命名空间是否已有持久化的存储（例如磁盘上的数据目录），用于只查找不创建的访问
+type StoreExists func(namespace string) bool
+
+// Namespaces 按命名空间隔离的剪切板存储，每个命名空间拥有独立的存储和容量限制，
+// 存储在命名空间首次被访问时创建
+type Namespaces struct {
+	factory StoreFactory
+	exists  StoreExists
+	stores  map[string]Store
+	mu      sync.Mutex
+}
+
+// NewNamespaces 创建按命名空间隔离的剪切板存储，exists为nil时只有已打开的存储视为存在
+func NewNamespaces(factory StoreFactory, exists StoreExists) *Namespaces {
+	return &Namespaces{
+		factory: factory,
+		exists:  exists,
+		stores:  make(map[string]Store),
+	}
+}
+
+// Get 获取命名空间的存储，不存在时创建
+func (n *Namespaces) Get(namespace string) (Store, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if store, ok := n.stores[namespace]; ok {
+		return store, nil
+	}
+
+	return n.open(namespace)
+}
+
+// Lookup 获取已存在的命名空间的存储，已持久化但尚未打开的存储会被打开；
+// 不会为不存在的命名空间创建存储和目录，不存在时返回ErrNamespaceNotFound
+func (n *Namespaces) Lookup(namespace string) (Store, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if store, ok := n.stores[namespace]; ok {
+		return store, nil
+	}
+	if n.exists == nil || !n.exists(namespace) {
+		return nil, ErrNamespaceNotFound
+	}
+
+	return n.open(namespace)
+}
+
+// open 创建并记录命名空间的存储，调用方需持有锁
+func (n *Namespaces) open(namespace string) (Store, error) {
+	store, err := n.factory(namespace)
+	if err != nil {
+		return nil, err
+	}
+	n.stores[namespace] = store
+
+	return store, nil
+}
+
+// Each 按命名空间名称顺序遍历所有已打开的存储
+func (n *Namespaces) Each(fn func(namespace string, store Store)) {
+	n.mu.Lock()
+	// 复制一份后释放锁，遍历期间可以打开新的命名空间
+	names := make([]string, 0, len(n.stores))
+	stores := make(map[string]Store, len(n.stores))
+	for name, store := range n.stores {
+		names = append(names, name)
+		stores[name] = store
+	}
+	n.mu.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		fn(name, stores[name])
+	}
+}
+
+// Remove 关闭并移除命名空间的存储（例如已关闭的房间），之后再次访问时会创建新的存储
+func (n *Namespaces) Remove(namespace string) error {
+	n.mu.Lock()
+	store, ok := n.stores[namespace]
+	delete(n.stores, namespace)
+	n.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return store.Close()
+}
+
+// Close 关闭所有已打开的存储，返回遇到的第一个错误
+func (n *Namespaces) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var firstErr error
+	for name, store := range n.stores {
+		if err := store.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(n.stores, name)
+	}
+
+	return firstErr
+}
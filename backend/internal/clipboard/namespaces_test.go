@@ -0,0 +1,46 @@
+package clipboard
+
+import "testing"
+
+func TestNamespacesLookup(t *testing.T) {
+	opened := map[string]int{}
+	persisted := map[string]bool{"saved": true}
+	n := NewNamespaces(func(namespace string) (Store, error) {
+		opened[namespace]++
+		return NewLRUCache(1024, 10), nil
+	}, func(namespace string) bool {
+		return persisted[namespace]
+	})
+
+	// 不存在的命名空间不会被创建
+	if _, err := n.Lookup("missing"); err != ErrNamespaceNotFound {
+		t.Fatalf("Lookup missing: err = %v, want ErrNamespaceNotFound", err)
+	}
+	if opened["missing"] != 0 {
+		t.Fatal("Lookup created a store for a missing namespace")
+	}
+
+	// 已持久化但尚未打开的命名空间在查找时打开
+	saved, err := n.Lookup("saved")
+	if err != nil {
+		t.Fatalf("Lookup saved: %v", err)
+	}
+	if again, err := n.Get("saved"); err != nil || again != saved || opened["saved"] != 1 {
+		t.Fatalf("Get saved = %v, %v, opened %d times", again, err, opened["saved"])
+	}
+
+	// 已打开的命名空间（例如不持久化的房间）可以查找
+	created, err := n.Get("room")
+	if err != nil {
+		t.Fatalf("Get room: %v", err)
+	}
+	if found, err := n.Lookup("room"); err != nil || found != created {
+		t.Fatalf("Lookup room = %v, %v", found, err)
+	}
+	if err := n.Remove("room"); err != nil {
+		t.Fatalf("Remove room: %v", err)
+	}
+	if _, err := n.Lookup("room"); err != ErrNamespaceNotFound {
+		t.Fatalf("Lookup removed room: err = %v, want ErrNamespaceNotFound", err)
+	}
+}
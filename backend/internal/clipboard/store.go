@@ -0,0 +1,56 @@
+package clipboard
+
+import "context"
+
+// Store 剪切板存储接口
+type Store interface {
+	// Put 添加或更新缓存项
+	Put(key, value string) error
+	// PutWithOptions 按指定选项添加或更新缓存项
+	PutWithOptions(key, value string, opts ItemOptions) error
+	// Get 获取缓存项，已过期的缓存项视为不存在，阅后即焚的缓存项在返回的同时被删除
+	Get(key string) (*CacheItem, bool)
+	// Delete 删除缓存项，缓存项不存在时返回false；删除无法持久化时返回错误，缓存项保持不变
+	Delete(key string) (bool, error)
+	// GetAll 获取所有未过期的缓存项（按最近访问排序），阅后即焚的缓存项不包含内容
+	GetAll() []*CacheItem
+	// Clear 清空所有缓存项，无法持久化时返回错误，缓存项保持不变
+	Clear() error
+	// GetSize 获取当前缓存大小
+	GetSize() int64
+	// GetCount 获取当前缓存项数量
+	GetCount() int
+	// RemoveExpired 清理所有已过期的缓存项，返回清理数量
+	RemoveExpired() int
+	// WaitNext 等待在afterKey之后写入的缓存项，ctx结束前没有新内容则返回false
+	WaitNext(ctx context.Context, afterKey string) (*CacheItem, bool)
+	// Close 关闭存储
+	Close() error
+}
+
+// 存储类型
+const (
+	StoreMemory = "memory"
+	StoreBolt   = "bolt"
+)
+
+// notifier 新内容通知，调用方需自行持有锁
+type notifier struct {
+	ch chan struct{}
+}
+
+// wait 获取下一次写入时会被关闭的通道
+func (n *notifier) wait() <-chan struct{} {
+	if n.ch == nil {
+		n.ch = make(chan struct{})
+	}
+	return n.ch
+}
+
+// broadcast 唤醒所有等待新内容的请求
+func (n *notifier) broadcast() {
+	if n.ch != nil {
+		close(n.ch)
+		n.ch = nil
+	}
+}
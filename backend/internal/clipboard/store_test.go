@@ -0,0 +1,196 @@
+package clipboard
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestStores 以相同的用例通过Store接口测试所有存储实现，容量为10字节、3条
+func TestStores(t *testing.T) {
+	stores := []struct {
+		name string
+		open func(t *testing.T, dir string) Store
+	}{
+		{"lru", func(t *testing.T, dir string) Store {
+			return openTestCache(t, dir, 10, 3)
+		}},
+		{"bolt", func(t *testing.T, dir string) Store {
+			s, err := NewBoltStore(filepath.Join(dir, "clipboard.db"), 10, 3)
+			if err != nil {
+				t.Fatalf("NewBoltStore: %v", err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		}},
+	}
+
+	// 用例通过open打开存储，同一用例中再次调用open时打开同一目录（需要先Close）
+	cases := []struct {
+		name string
+		run  func(t *testing.T, open func() Store)
+	}{
+		{"put and get", func(t *testing.T, open func() Store) {
+			s := open()
+			mustPut(t, s, "a", "1", ItemOptions{})
+			mustPut(t, s, "a", "22", ItemOptions{})
+			if item, ok := s.Get("a"); !ok || item.Value != "22" || item.Size != 2 {
+				t.Fatalf("Get a = %+v, %v", item, ok)
+			}
+			if _, ok := s.Get("missing"); ok {
+				t.Fatal("Get returned a missing item")
+			}
+			if s.GetSize() != 2 || s.GetCount() != 1 {
+				t.Fatalf("size = %d, count = %d, want 2, 1", s.GetSize(), s.GetCount())
+			}
+			if err := s.Put("big", "12345678901"); err != ErrItemSizeExceeded {
+				t.Fatalf("Put oversized item: err = %v, want ErrItemSizeExceeded", err)
+			}
+		}},
+		{"delete", func(t *testing.T, open func() Store) {
+			s := open()
+			mustPut(t, s, "a", "1", ItemOptions{})
+			if ok, err := s.Delete("a"); !ok || err != nil {
+				t.Fatalf("Delete a = %v, %v", ok, err)
+			}
+			if ok, err := s.Delete("a"); ok || err != nil {
+				t.Fatalf("second Delete a = %v, %v", ok, err)
+			}
+			if s.GetSize() != 0 || s.GetCount() != 0 {
+				t.Fatalf("size = %d, count = %d, want 0, 0", s.GetSize(), s.GetCount())
+			}
+		}},
+		{"evicts least recently used", func(t *testing.T, open func() Store) {
+			s := open()
+			for _, key := range []string{"a", "b", "c"} {
+				mustPut(t, s, key, "12", ItemOptions{})
+			}
+			s.Get("a")
+			mustPut(t, s, "d", "12", ItemOptions{})
+			if got, want := keys(s.GetAll()), []string{"d", "a", "c"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("keys after count eviction = %v, want %v", got, want)
+			}
+			mustPut(t, s, "e", "12345678", ItemOptions{})
+			if got, want := keys(s.GetAll()), []string{"e", "d"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("keys after size eviction = %v, want %v", got, want)
+			}
+			if s.GetSize() != 10 || s.GetCount() != 2 {
+				t.Fatalf("size = %d, count = %d, want 10, 2", s.GetSize(), s.GetCount())
+			}
+		}},
+		{"expiry", func(t *testing.T, open func() Store) {
+			s := open()
+			now := time.Now().UnixMilli()
+			mustPut(t, s, "expired", "1", ItemOptions{ExpiresAt: now - 1})
+			mustPut(t, s, "live", "2", ItemOptions{ExpiresAt: now + time.Hour.Milliseconds()})
+			if got, want := keys(s.GetAll()), []string{"live"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("GetAll = %v, want %v", got, want)
+			}
+			if n := s.RemoveExpired(); n != 1 {
+				t.Fatalf("RemoveExpired = %d, want 1", n)
+			}
+			if _, ok := s.Get("expired"); ok {
+				t.Fatal("Get returned an expired item")
+			}
+			if item, ok := s.Get("live"); !ok || item.ExpiresAt != now+time.Hour.Milliseconds() {
+				t.Fatalf("Get live = %+v, %v", item, ok)
+			}
+		}},
+		{"read once", func(t *testing.T, open func() Store) {
+			s := open()
+			mustPut(t, s, "secret", "burn", ItemOptions{ReadOnce: true})
+			if items := s.GetAll(); len(items) != 1 || items[0].Value != "" || !items[0].ReadOnce {
+				t.Fatalf("GetAll = %+v", items)
+			}
+			if item, ok := s.Get("secret"); !ok || item.Value != "burn" {
+				t.Fatalf("first Get = %+v, %v", item, ok)
+			}
+			if _, ok := s.Get("secret"); ok {
+				t.Fatal("read-once item returned twice")
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if _, ok := open().Get("secret"); ok {
+				t.Fatal("read-once item returned after reopening")
+			}
+		}},
+		{"clear", func(t *testing.T, open func() Store) {
+			s := open()
+			mustPut(t, s, "a", "1", ItemOptions{})
+			mustPut(t, s, "b", "2", ItemOptions{})
+			if err := s.Clear(); err != nil {
+				t.Fatalf("Clear: %v", err)
+			}
+			if len(s.GetAll()) != 0 || s.GetSize() != 0 || s.GetCount() != 0 {
+				t.Fatalf("after Clear: items = %v, size = %d, count = %d", keys(s.GetAll()), s.GetSize(), s.GetCount())
+			}
+		}},
+		{"wait next", func(t *testing.T, open func() Store) {
+			s := open()
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if item, ok := s.WaitNext(ctx, ""); ok {
+				t.Fatalf("WaitNext returned %+v without a write", item)
+			}
+
+			done := make(chan *CacheItem, 1)
+			go func() {
+				item, _ := s.WaitNext(context.Background(), "")
+				done <- item
+			}()
+			time.Sleep(10 * time.Millisecond)
+			mustPut(t, s, "a", "1", ItemOptions{})
+			select {
+			case item := <-done:
+				if item == nil || item.Key != "a" {
+					t.Fatalf("WaitNext woke with %+v", item)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("WaitNext was not woken by Put")
+			}
+
+			mustPut(t, s, "b", "2", ItemOptions{ReadOnce: true})
+			if item, ok := s.WaitNext(context.Background(), "a"); !ok || item.Key != "b" || item.Value != "" {
+				t.Fatalf("WaitNext after a = %+v, %v", item, ok)
+			}
+		}},
+		{"reopen", func(t *testing.T, open func() Store) {
+			s := open()
+			mustPut(t, s, "a", "1", ItemOptions{})
+			mustPut(t, s, "b", "2", ItemOptions{})
+			s.Get("a")
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			s = open()
+			if got, want := keys(s.GetAll()), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("keys after reopening = %v, want %v", got, want)
+			}
+			if s.GetSize() != 2 || s.GetCount() != 2 {
+				t.Fatalf("size = %d, count = %d, want 2, 2", s.GetSize(), s.GetCount())
+			}
+		}},
+	}
+
+	for _, store := range stores {
+		for _, tc := range cases {
+			t.Run(store.name+"/"+tc.name, func(t *testing.T) {
+				dir := t.TempDir()
+				tc.run(t, func() Store { return store.open(t, dir) })
+			})
+		}
+	}
+}
+
+// mustPut 写入缓存项，失败时终止测试
+func mustPut(t *testing.T, s Store, key, value string, opts ItemOptions) {
+	t.Helper()
+
+	if err := s.PutWithOptions(key, value, opts); err != nil {
+		t.Fatalf("Put %s: %v", key, err)
+	}
+}
@@ -10,12 +10,54 @@ const (
 	ErrCodeTotalStorageExceeded = 40002
 	// ErrCodeInvalidFileFormat 文件格式无效
 	ErrCodeInvalidFileFormat = 40003
+	// ErrCodeInvalidUploadRequest 断点续传请求参数无效
+	ErrCodeInvalidUploadRequest = 40004
+	// ErrCodeChecksumMismatch 文件内容与客户端提供的校验和不一致
+	ErrCodeChecksumMismatch = 40005
+	// ErrCodeInvalidChecksum 客户端提供的校验和格式无效
+	ErrCodeInvalidChecksum = 40006
+	// ErrCodeMalformedUpload 上传请求不是有效的multipart/form-data或缺少file字段
+	ErrCodeMalformedUpload = 40007
+	// ErrCodeInvalidSharePolicy 分享策略（有效期、下载次数、访问密码）无效或超过上限
+	ErrCodeInvalidSharePolicy = 40008
+	// ErrCodeInvalidTokenRequest 创建令牌的参数无效
+	ErrCodeInvalidTokenRequest = 40009
+	// ErrCodeNamespaceQuotaExceeded 命名空间存储容量超过限制
+	ErrCodeNamespaceQuotaExceeded = 40010
+	// ErrCodeInvalidRoomRequest 创建或加入房间的参数无效
+	ErrCodeInvalidRoomRequest = 40011
+	// ErrCodeInvalidShareRequest 分享链接的参数（有效期、使用次数、访问密码）无效或超过上限
+	ErrCodeInvalidShareRequest = 40012
+)
+
+// 401 Unauthorized
+const (
+	// ErrCodeFilePasswordRequired 文件需要访问密码
+	ErrCodeFilePasswordRequired = 40101
+	// ErrCodeFilePasswordIncorrect 文件访问密码错误
+	ErrCodeFilePasswordIncorrect = 40102
+	// ErrCodeAuthRequired 需要身份认证
+	ErrCodeAuthRequired = 40103
+	// ErrCodeInvalidToken 令牌无效或已过期
+	ErrCodeInvalidToken = 40104
+	// ErrCodeInvalidRoomToken 房间令牌无效或房间已过期
+	ErrCodeInvalidRoomToken = 40105
+	// ErrCodeSharePasswordRequired 分享链接需要访问密码
+	ErrCodeSharePasswordRequired = 40106
+	// ErrCodeSharePasswordIncorrect 分享链接访问密码错误
+	ErrCodeSharePasswordIncorrect = 40107
 )
 
 // 403 Forbidden
 const (
 	// ErrCodeDownloadLimitReached 文件下载次数已达上限
 	ErrCodeDownloadLimitReached = 40301
+	// ErrCodeInsufficientScope 令牌权限不足
+	ErrCodeInsufficientScope = 40302
+	// ErrCodeRoomFull 房间设备数已达上限
+	ErrCodeRoomFull = 40303
+	// ErrCodeShareUsesExhausted 分享链接使用次数已用完
+	ErrCodeShareUsesExhausted = 40304
 )
 
 // 404 Not Found
@@ -24,6 +66,54 @@ const (
 	ErrCodeFileNotFound = 40401
 	// ErrCodeFileDeleted 文件已被删除
 	ErrCodeFileDeleted = 40402
+	// ErrCodeUploadNotFound 上传会话不存在
+	ErrCodeUploadNotFound = 40403
+	// ErrCodeTokenNotFound 令牌不存在
+	ErrCodeTokenNotFound = 40404
+	// ErrCodeRoomNotFound 房间不存在、配对码无效或当前请求不属于任何房间
+	ErrCodeRoomNotFound = 40405
+	// ErrCodeShareNotFound 分享链接无效、已吊销或分享的内容已被删除
+	ErrCodeShareNotFound = 40406
+)
+
+// 409 Conflict
+const (
+	// ErrCodeUploadOffsetMismatch 上传偏移量与服务端不一致
+	ErrCodeUploadOffsetMismatch = 40901
+)
+
+// 410 Gone
+const (
+	// ErrCodeFileExpired 文件已过期
+	ErrCodeFileExpired = 41001
+	// ErrCodeShareExpired 分享链接已过期
+	ErrCodeShareExpired = 41002
+)
+
+// 412 Precondition Failed
+const (
+	// ErrCodeUnsupportedTusVersion 不支持的tus协议版本
+	ErrCodeUnsupportedTusVersion = 41201
+)
+
+// 415 Unsupported Media Type
+const (
+	// ErrCodeInvalidUploadContentType 上传数据的Content-Type无效
+	ErrCodeInvalidUploadContentType = 41501
+)
+
+// 416 Range Not Satisfiable
+const (
+	// ErrCodeRangeNotSatisfiable 请求的下载范围无效
+	ErrCodeRangeNotSatisfiable = 41601
+)
+
+// 429 Too Many Requests
+const (
+	// ErrCodeTooManyJoinAttempts 加入房间失败次数过多
+	ErrCodeTooManyJoinAttempts = 42901
+	// ErrCodeTooManyPasswordAttempts 访问密码错误次数过多
+	ErrCodeTooManyPasswordAttempts = 42902
 )
 
 // 500 Internal Server Error
@@ -48,4 +138,38 @@ const (
 	ErrCodeOpenFileFailed = 50009
 	// ErrCodeDeleteFileFailed 删除文件失败
 	ErrCodeDeleteFileFailed = 50010
+	// ErrCodeCreateUploadFailed 创建上传会话失败
+	ErrCodeCreateUploadFailed = 50011
+	// ErrCodeWriteUploadFailed 写入上传数据失败
+	ErrCodeWriteUploadFailed = 50012
+	// ErrCodeFileCorrupted 文件内容已损坏
+	ErrCodeFileCorrupted = 50013
+	// ErrCodeThumbnailFailed 生成缩略图失败
+	ErrCodeThumbnailFailed = 50014
+	// ErrCodePreviewFailed 生成预览失败
+	ErrCodePreviewFailed = 50015
+	// ErrCodeAuthFailed 身份认证失败
+	ErrCodeAuthFailed = 50016
+	// ErrCodeCreateTokenFailed 创建令牌失败
+	ErrCodeCreateTokenFailed = 50017
+	// ErrCodeListTokensFailed 获取令牌列表失败
+	ErrCodeListTokensFailed = 50018
+	// ErrCodeRevokeTokenFailed 吊销令牌失败
+	ErrCodeRevokeTokenFailed = 50019
+	// ErrCodeCreateRoomFailed 创建或加入房间失败
+	ErrCodeCreateRoomFailed = 50020
+	// ErrCodeCreateShareFailed 创建分享链接失败
+	ErrCodeCreateShareFailed = 50021
+	// ErrCodeUpdateShareFailed 更新或吊销分享链接失败
+	ErrCodeUpdateShareFailed = 50022
+	// ErrCodeSharePolicyFailed 处理分享策略失败（例如计算密码哈希失败）
+	ErrCodeSharePolicyFailed = 50023
+	// ErrCodeScrubFilesFailed 校验文件完整性失败
+	ErrCodeScrubFilesFailed = 50024
+)
+
+// 503 Service Unavailable
+const (
+	// ErrCodeTooManyRooms 房间数量已达上限
+	ErrCodeTooManyRooms = 50301
 )
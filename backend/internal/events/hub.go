@@ -0,0 +1,169 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	TypeClipboardUpload = "clipboard.upload"
+	TypeClipboardDelete = "clipboard.delete"
+	TypeClipboardClear  = "clipboard.clear"
+	TypeClipboardExpire = "clipboard.expire"
+	TypeFileUpload      = "file.upload"
+	TypeFileDelete      = "file.delete"
+	TypeFileCleanup     = "file.cleanup"
+	TypeFileCorrupt     = "file.corrupt"
+	TypeRoomJoin        = "room.join"
+	TypeRoomLeave       = "room.leave"
+	TypeRoomClose       = "room.close"
+	// TypeResync 客户端错过的事件已不在历史记录中，需要重新拉取全量数据
+	TypeResync = "resync"
+)
+
+// subscriberBufferSize 每个订阅者的事件缓冲区大小
+const subscriberBufferSize = 64
+
+// Event 事件，事件ID在所有命名空间之间全局递增，订阅者收到的ID可能不连续
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+	Time int64       `json:"time"`
+
+	namespace string
+	global    bool
+}
+
+// visibleTo 事件是否推送给命名空间的订阅者
+func (e *Event) visibleTo(namespace string) bool {
+	return e.global || e.namespace == namespace
+}
+
+// Hub 事件中心，负责向订阅者推送事件并保留最近的事件用于断线续传，
+// 事件按命名空间隔离，只推送给同一命名空间的订阅者
+type Hub struct {
+	historySize int
+	history     []*Event
+	lastID      uint64
+	subscribers map[*Subscription]struct{}
+	mu          sync.Mutex
+}
+
+// Subscription 事件订阅
+type Subscription struct {
+	C         <-chan *Event
+	ch        chan *Event
+	hub       *Hub
+	namespace string
+	once      sync.Once
+}
+
+// NewHub 创建新的事件中心
+func NewHub(historySize int) *Hub {
+	return &Hub{
+		historySize: historySize,
+		history:     make([]*Event, 0, historySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish 向命名空间发布事件，namespace为空表示公共命名空间
+func (h *Hub) Publish(namespace, eventType string, data interface{}) *Event {
+	return h.publish(&Event{Type: eventType, Data: data, namespace: namespace})
+}
+
+// Broadcast 向所有命名空间发布不包含具体内容的事件（例如清理统计）
+func (h *Hub) Broadcast(eventType string, data interface{}) *Event {
+	return h.publish(&Event{Type: eventType, Data: data, global: true})
+}
+
+// publish 分配事件ID并推送给可见的订阅者
+func (h *Hub) publish(event *Event) *Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event.ID = h.lastID
+	event.Time = time.Now().UnixMilli()
+
+	// 保留最近的事件
+	if h.historySize > 0 {
+		if len(h.history) >= h.historySize {
+			h.history = append(h.history[:0], h.history[1:]...)
+		}
+		h.history = append(h.history, event)
+	}
+
+	for sub := range h.subscribers {
+		if !event.visibleTo(sub.namespace) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// 订阅者消费过慢，断开连接，由客户端携带最后事件ID重连续传
+			h.unsubscribe(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe 订阅命名空间的事件，lastEventID不为0时返回其后错过的事件
+func (h *Hub) Subscribe(namespace string, lastEventID uint64) (*Subscription, []*Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan *Event, subscriberBufferSize)
+	sub := &Subscription{C: ch, ch: ch, hub: h, namespace: namespace}
+	h.subscribers[sub] = struct{}{}
+
+	return sub, h.missed(namespace, lastEventID)
+}
+
+// missed 在已持有锁的情况下获取命名空间中lastEventID之后的事件
+func (h *Hub) missed(namespace string, lastEventID uint64) []*Event {
+	if lastEventID == 0 || lastEventID == h.lastID {
+		return nil
+	}
+
+	// 服务重启或事件已被淘汰，无法续传
+	oldest := h.lastID + 1
+	if len(h.history) > 0 {
+		oldest = h.history[0].ID
+	}
+	if lastEventID > h.lastID || lastEventID+1 < oldest {
+		return []*Event{{
+			ID:   h.lastID,
+			Type: TypeResync,
+			Time: time.Now().UnixMilli(),
+		}}
+	}
+
+	missed := make([]*Event, 0, h.lastID-lastEventID)
+	for _, event := range h.history {
+		if event.ID > lastEventID && event.visibleTo(namespace) {
+			missed = append(missed, event)
+		}
+	}
+
+	return missed
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.unsubscribe(s)
+}
+
+// unsubscribe 在已持有锁的情况下移除订阅者
+func (h *Hub) unsubscribe(sub *Subscription) {
+	sub.once.Do(func() {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	})
+}
@@ -0,0 +1,187 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"cloud-clipboard/internal/logger"
+)
+
+var (
+	// filesBucket 文件ID -> 元数据
+	filesBucket = []byte("files")
+	// metaBucket 存储自身的状态信息
+	metaBucket = []byte("meta")
+	// migratedKey 是否已导入旧版files.json
+	migratedKey = []byte("jsonMigrated")
+)
+
+// boltMetadataStore 基于bbolt的元数据存储，启动时将所有元数据载入内存索引，
+// 读操作只访问内存，写操作在事务提交成功后再更新索引
+type boltMetadataStore struct {
+	db    *bolt.DB
+	index map[string]*FileMetadata
+}
+
+// NewBoltMetadataStore 打开或创建基于bbolt的元数据存储，
+// legacyFile不为空且尚未导入时，将旧版JSON元数据（或其最近的有效备份）一次性导入
+func NewBoltMetadataStore(path, legacyFile string, legacyBackups int) (MetadataStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata database: %w", err)
+	}
+
+	s := &boltMetadataStore{
+		db:    db,
+		index: make(map[string]*FileMetadata),
+	}
+
+	if err := s.load(legacyFile, legacyBackups); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// load 导入旧版元数据并构建内存索引
+func (s *boltMetadataStore) load(legacyFile string, legacyBackups int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		files, err := tx.CreateBucketIfNotExists(filesBucket)
+		if err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		if legacyFile != "" && meta.Get(migratedKey) == nil {
+			imported, err := importLegacyMetadata(files, legacyFile, legacyBackups)
+			if err != nil {
+				return err
+			}
+			if err := meta.Put(migratedKey, []byte(time.Now().Format(time.RFC3339))); err != nil {
+				return err
+			}
+			if imported > 0 {
+				logger.Infof("Imported %d file metadata records from %s", imported, legacyFile)
+			}
+		}
+
+		return files.ForEach(func(k, v []byte) error {
+			var file FileMetadata
+			if err := json.Unmarshal(v, &file); err != nil {
+				return fmt.Errorf("failed to unmarshal metadata %s: %w", k, err)
+			}
+			s.index[file.ID] = &file
+			return nil
+		})
+	})
+}
+
+// importLegacyMetadata 将旧版JSON元数据写入bucket，返回导入数量
+func importLegacyMetadata(files *bolt.Bucket, legacyFile string, legacyBackups int) (int, error) {
+	metadata, _, err := readMetadataWithBackups(legacyFile, legacyBackups)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to import legacy metadata: %w", err)
+	}
+
+	for _, file := range metadata {
+		data, err := json.Marshal(file)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		if err := files.Put([]byte(file.ID), data); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(metadata), nil
+}
+
+// List 获取所有文件元数据（按上传时间排序）
+func (s *boltMetadataStore) List() ([]*FileMetadata, error) {
+	metadata := make([]*FileMetadata, 0, len(s.index))
+	for _, file := range s.index {
+		copied := *file
+		metadata = append(metadata, &copied)
+	}
+
+	sort.Slice(metadata, func(i, j int) bool {
+		return metadata[i].UploadTime < metadata[j].UploadTime
+	})
+
+	return metadata, nil
+}
+
+// Get 获取文件元数据
+func (s *boltMetadataStore) Get(id string) (*FileMetadata, error) {
+	file, ok := s.index[id]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+
+	copied := *file
+	return &copied, nil
+}
+
+// Put 添加或更新文件元数据
+func (s *boltMetadataStore) Put(file *FileMetadata) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(filesBucket).Put([]byte(file.ID), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	copied := *file
+	s.index[file.ID] = &copied
+	return nil
+}
+
+// Delete 删除文件元数据
+func (s *boltMetadataStore) Delete(ids ...string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		files := tx.Bucket(filesBucket)
+		for _, id := range ids {
+			if err := files.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete metadata: %w", err)
+	}
+
+	for _, id := range ids {
+		delete(s.index, id)
+	}
+	return nil
+}
+
+// Close 关闭数据库
+func (s *boltMetadataStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,185 @@
+package file
+
+import (
+	"fmt"
+	"time"
+)
+
+// resumeWindow 中断的下载可以免计数续传的时间
+const resumeWindow = time.Hour
+
+// DownloadReservation 一次进行中的下载，由ReserveDownload创建，传输结束后必须调用FinishDownload
+type DownloadReservation struct {
+	// File 预留时的文件元数据
+	File *FileMetadata
+	// Counted 本次下载是否占用了下载次数
+	Counted bool
+	// Resumed 本次下载是否从服务端记录的中断位置续传
+	Resumed bool
+	// Written 已传输的字节数，由调用方在传输结束后设置，未到达文件末尾时记录为续传位置
+	Written  int64
+	client   string
+	offset   int64
+	previous resumePoint
+	finished bool
+}
+
+// ReserveDownload 原子地检查并增加下载次数，client标识请求方，offset为单个范围请求的起始位置，完整下载和多范围请求为0。
+// 只有同一请求方从服务端记录的中断位置续传的请求不计数，客户端无法通过跳过首字节绕过下载次数限制；
+// 不限制下载次数的文件只用于统计，不包含首字节的请求在已被下载过时同样不计数（例如视频拖动）。
+// 下载次数已达上限时返回ErrMaxDownloadsReached
+func (s *FileService) ReserveDownload(id, client string, offset int64) (*DownloadReservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := s.metadata.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous resumePoint
+	resumed := false
+	if offset > 0 {
+		previous, resumed = s.takeResumePoint(id, client, offset)
+	}
+	counted := !resumed && (offset == 0 || file.MaxDownloads > 0 || file.DownloadCount == 0)
+	if counted {
+		if file.downloadLimitReached() {
+			return nil, ErrMaxDownloadsReached
+		}
+		file.DownloadCount++
+	}
+	file.LastAccessTime = time.Now().UnixMilli()
+
+	if err := s.metadata.Put(file); err != nil {
+		if resumed {
+			s.addResumePoint(id, previous)
+		}
+		return nil, err
+	}
+	s.downloads[id]++
+
+	return &DownloadReservation{
+		File:     file,
+		Counted:  counted,
+		Resumed:  resumed,
+		client:   client,
+		offset:   offset,
+		previous: previous,
+	}, nil
+}
+
+// FinishDownload 结束一次下载，refund为true时退还本次占用的下载次数；
+// 传输未到达文件末尾且未退还时记录续传位置，同一请求方从该位置续传的下一次请求不再计数；
+// completed为true、已传输到文件末尾且下载次数有限并已用完、没有其他进行中的下载时自动删除文件，返回文件是否已被删除
+func (s *FileService) FinishDownload(res *DownloadReservation, completed, refund bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if res.finished {
+		return false, nil
+	}
+	res.finished = true
+
+	id := res.File.ID
+	s.downloads[id]--
+	if s.downloads[id] <= 0 {
+		delete(s.downloads, id)
+	}
+
+	file, err := s.metadata.Get(id)
+	if err == ErrFileNotFound {
+		// 下载期间文件已被删除
+		delete(s.resumes, id)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if refund {
+		if res.Counted && file.DownloadCount > 0 {
+			file.DownloadCount--
+			if err := s.metadata.Put(file); err != nil {
+				return false, fmt.Errorf("failed to refund download: %w", err)
+			}
+		} else if res.Resumed {
+			// 续传失败时恢复原来的续传位置
+			s.addResumePoint(id, res.previous)
+		}
+		return false, nil
+	}
+
+	if end := res.offset + res.Written; end > 0 && end < file.Size {
+		// 文件还没有完整传输，保留文件等待续传
+		s.addResumePoint(id, resumePoint{client: res.client, start: res.offset, end: end})
+		return false, nil
+	}
+
+	if !completed || !file.downloadLimitReached() || s.downloads[id] > 0 {
+		return false, nil
+	}
+
+	// 最后一次允许的下载已完成
+	key := file.BlobKey()
+	if err := s.metadata.Delete(id); err != nil {
+		return false, err
+	}
+	delete(s.resumes, id)
+	if s.release(key) == 0 {
+		if err := s.deleteBlob(key); err != nil {
+			return true, fmt.Errorf("failed to delete file: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// resumePoint 一次中断的下载，client从[start, end]范围内的位置重新请求时视为续传
+type resumePoint struct {
+	client    string
+	start     int64
+	end       int64
+	expiresAt time.Time
+}
+
+// addResumePoint 记录文件id的一次中断下载，同时清理所有已过期的续传位置，调用方需持有锁
+func (s *FileService) addResumePoint(id string, point resumePoint) {
+	now := time.Now()
+	for fileID, points := range s.resumes {
+		live := points[:0]
+		for _, p := range points {
+			if now.Before(p.expiresAt) {
+				live = append(live, p)
+			}
+		}
+		if len(live) == 0 {
+			delete(s.resumes, fileID)
+		} else {
+			s.resumes[fileID] = live
+		}
+	}
+
+	point.expiresAt = now.Add(resumeWindow)
+	s.resumes[id] = append(s.resumes[id], point)
+}
+
+// takeResumePoint 查找并消耗client在文件id中覆盖offset的续传位置，每个位置只能使用一次；
+// 由于传输中断时服务端已发送的数据可能多于客户端实际收到的，续传位置允许不超过已发送的末尾，调用方需持有锁
+func (s *FileService) takeResumePoint(id, client string, offset int64) (resumePoint, bool) {
+	now := time.Now()
+	points := s.resumes[id]
+	for i, p := range points {
+		if p.client != client || offset <= p.start || offset > p.end || !now.Before(p.expiresAt) {
+			continue
+		}
+
+		s.resumes[id] = append(points[:i], points[i+1:]...)
+		if len(s.resumes[id]) == 0 {
+			delete(s.resumes, id)
+		}
+		return p, true
+	}
+
+	return resumePoint{}, false
+}
@@ -0,0 +1,194 @@
+package file
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/storage"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = logrus.New()
+	logger.Logger.SetOutput(io.Discard)
+
+	os.Exit(m.Run())
+}
+
+// newTestService 创建使用临时目录的文件服务
+func newTestService(t *testing.T) *FileService {
+	t.Helper()
+
+	dir := t.TempDir()
+	blobStorage, err := storage.NewLocalStorage(filepath.Join(dir, "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	metadataStore, err := NewJSONMetadataStore(filepath.Join(dir, "metadata.json"), 0)
+	if err != nil {
+		t.Fatalf("NewJSONMetadataStore: %v", err)
+	}
+	s, err := NewFileService(blobStorage, metadataStore, 0, 0, TypePolicy{})
+	if err != nil {
+		t.Fatalf("NewFileService: %v", err)
+	}
+
+	return s
+}
+
+// addTestFile 添加内容为content、最多允许下载maxDownloads次的文件
+func addTestFile(t *testing.T, s *FileService, content string, maxDownloads int) *FileMetadata {
+	t.Helper()
+
+	file, err := s.AddFile(strings.NewReader(content), &FileInfo{
+		OriginalName: "test.txt",
+		Size:         int64(len(content)),
+		Mimetype:     "text/plain",
+		SharePolicy:  SharePolicy{MaxDownloads: maxDownloads},
+	})
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+
+	return file
+}
+
+func TestReserveDownload(t *testing.T) {
+	type step struct {
+		client  string
+		offset  int64
+		written int64
+		refund  bool
+		want    error
+		counted bool
+		resumed bool
+	}
+
+	tests := []struct {
+		name         string
+		maxDownloads int
+		steps        []step
+	}{
+		{
+			name:         "skipping the first byte is counted",
+			maxDownloads: 2,
+			steps: []step{
+				{client: "a", offset: 1, counted: true},
+				{client: "a", offset: 1, counted: true},
+				{client: "a", offset: 1, want: ErrMaxDownloadsReached},
+				{client: "a", offset: 0, want: ErrMaxDownloadsReached},
+			},
+		},
+		{
+			name:         "interrupted download resumes once",
+			maxDownloads: 1,
+			steps: []step{
+				{client: "a", offset: 0, written: 6, counted: true},
+				{client: "a", offset: 4, resumed: true},
+				{client: "a", offset: 4, want: ErrMaxDownloadsReached},
+			},
+		},
+		{
+			name:         "resumed download can be interrupted again",
+			maxDownloads: 1,
+			steps: []step{
+				{client: "a", offset: 0, written: 3, counted: true},
+				{client: "a", offset: 3, written: 3, resumed: true},
+				{client: "a", offset: 6, resumed: true},
+				{client: "a", offset: 8, want: ErrMaxDownloadsReached},
+			},
+		},
+		{
+			name:         "resume point is bound to the client",
+			maxDownloads: 1,
+			steps: []step{
+				{client: "a", offset: 0, written: 6, counted: true},
+				{client: "b", offset: 4, want: ErrMaxDownloadsReached},
+				{client: "a", offset: 4, resumed: true},
+			},
+		},
+		{
+			name:         "offset beyond the sent bytes is counted",
+			maxDownloads: 1,
+			steps: []step{
+				{client: "a", offset: 0, written: 6, counted: true},
+				{client: "a", offset: 7, want: ErrMaxDownloadsReached},
+			},
+		},
+		{
+			name:         "refunded resume keeps the resume point",
+			maxDownloads: 1,
+			steps: []step{
+				{client: "a", offset: 0, written: 6, counted: true},
+				{client: "a", offset: 4, refund: true, resumed: true},
+				{client: "a", offset: 5, resumed: true},
+			},
+		},
+		{
+			name:         "refunded download records no resume point",
+			maxDownloads: 1,
+			steps: []step{
+				{client: "a", offset: 0, written: 6, refund: true, counted: true},
+				{client: "a", offset: 4, counted: true},
+			},
+		},
+		{
+			name:         "unlimited file counts only the first request skipping the first byte",
+			maxDownloads: 0,
+			steps: []step{
+				{client: "a", offset: 5, counted: true},
+				{client: "b", offset: 5},
+				{client: "a", offset: 0, counted: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			file := addTestFile(t, s, "0123456789", tt.maxDownloads)
+
+			for i, step := range tt.steps {
+				res, err := s.ReserveDownload(file.ID, step.client, step.offset)
+				if err != step.want {
+					t.Fatalf("step %d: ReserveDownload err = %v, want %v", i, err, step.want)
+				}
+				if err != nil {
+					continue
+				}
+				if res.Counted != step.counted || res.Resumed != step.resumed {
+					t.Fatalf("step %d: counted = %v, resumed = %v, want %v, %v", i, res.Counted, res.Resumed, step.counted, step.resumed)
+				}
+
+				res.Written = step.written
+				if _, err := s.FinishDownload(res, false, step.refund); err != nil {
+					t.Fatalf("step %d: FinishDownload: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFinishDownloadDeletesAfterLastDownload(t *testing.T) {
+	s := newTestService(t)
+	file := addTestFile(t, s, "0123456789", 1)
+
+	res, err := s.ReserveDownload(file.ID, "a", 0)
+	if err != nil {
+		t.Fatalf("ReserveDownload: %v", err)
+	}
+	res.Written = file.Size
+	deleted, err := s.FinishDownload(res, true, false)
+	if err != nil || !deleted {
+		t.Fatalf("FinishDownload = %v, %v, want true, nil", deleted, err)
+	}
+
+	if _, err := s.ReserveDownload(file.ID, "a", 0); err != ErrFileNotFound {
+		t.Fatalf("ReserveDownload after delete: err = %v, want ErrFileNotFound", err)
+	}
+}
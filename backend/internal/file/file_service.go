@@ -1,153 +1,249 @@
 package file
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io/fs"
-	"os"
+	"io"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/storage"
 )
 
 // FileService 文件服务
 type FileService struct {
-	metadataPath string
-	uploadDir    string
-	mu           sync.RWMutex
+	metadata      MetadataStore
+	storage       storage.Storage
+	refs          map[string]int
+	thumbnails    map[string]map[string]int64
+	thumbnailSize int
+	previewSize   int
+	types         TypePolicy
+	reserved      int64
+	reservedBy    map[string]int64
+	downloads     map[string]int
+	resumes       map[string][]resumePoint
+	mu            sync.RWMutex
 }
 
-// FileMetadata 文件元数据
+// FileMetadata 文件元数据，Owner为文件所属的命名空间，为空表示公共命名空间（包括引入命名空间之前上传的文件）
 type FileMetadata struct {
-	ID             string `json:"id"`
-	Filename       string `json:"filename"`
-	Size           int64  `json:"size"`
-	Mimetype       string `json:"mimetype"`
-	FilePath       string `json:"filePath"`
-	UploadTime     int64  `json:"uploadTime"`
-	LastAccessTime int64  `json:"lastAccessTime"`
-	DownloadCount  int    `json:"downloadCount"`
-	MaxDownloads   int    `json:"maxDownloads"`
+	ID       string `json:"id"`
+	Owner    string `json:"owner,omitempty"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Mimetype string `json:"mimetype"`
+	// DeclaredMimetype 客户端声明的类型，仅供参考，Mimetype为根据内容检测出的类型
+	DeclaredMimetype string `json:"declaredMimetype,omitempty"`
+	StorageKey       string `json:"storageKey,omitempty"`
+	Digest           string `json:"digest,omitempty"`
+	Corrupt          bool   `json:"corrupt,omitempty"`
+	VerifiedAt       int64  `json:"verifiedAt,omitempty"`
+	FilePath         string `json:"filePath,omitempty"`
+	UploadTime       int64  `json:"uploadTime"`
+	LastAccessTime   int64  `json:"lastAccessTime"`
+	DownloadCount    int    `json:"downloadCount"`
+	MaxDownloads     int    `json:"maxDownloads"`
+	ExpiresAt        int64  `json:"expiresAt,omitempty"`
+	PasswordHash     string `json:"passwordHash,omitempty"`
 }
 
-// NewFileService 创建新的文件服务
-func NewFileService(uploadDir, metadataFile string) (*FileService, error) {
-	// 确保上传目录存在
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create upload directory: %w", err)
-	}
-
-	// 确保元数据文件目录存在
-	metadataDir := filepath.Dir(metadataFile)
-	if err := os.MkdirAll(metadataDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create metadata directory: %w", err)
-	}
-
-	// 确保元数据文件存在
-	if _, err := os.Stat(metadataFile); os.IsNotExist(err) {
-		if err := os.WriteFile(metadataFile, []byte("[]"), 0644); err != nil {
-			return nil, fmt.Errorf("failed to create metadata file: %w", err)
-		}
+// BlobKey 获取文件内容在存储中的键，兼容旧版本记录的本地文件路径
+func (m *FileMetadata) BlobKey() string {
+	if m.StorageKey != "" {
+		return m.StorageKey
 	}
-
-	return &FileService{
-		metadataPath: metadataFile,
-		uploadDir:    uploadDir,
-	}, nil
+	return filepath.Base(m.FilePath)
 }
 
-// ReadMetadata 读取元数据
-func (s *FileService) ReadMetadata() ([]*FileMetadata, error) {
-	data, err := os.ReadFile(s.metadataPath)
+// NewFileService 创建新的文件服务，内容按SHA-256去重存储；元数据是引用关系的唯一来源，
+// 每个存储键的引用计数根据元数据中引用该键的文件数量建立，不单独保存，启动时对照存储清理无主的内容（见scanStorage），
+// thumbnailSize和previewSize分别为缩略图和预览图片长边的最大像素数，types限制允许上传的文件类型
+func NewFileService(blobStorage storage.Storage, metadataStore MetadataStore, thumbnailSize, previewSize int, types TypePolicy) (*FileService, error) {
+	metadata, err := metadataStore.List()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read metadata file: %w", err)
+		return nil, err
 	}
 
-	var metadata []*FileMetadata
-	if err := json.Unmarshal(data, &metadata); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	refs := make(map[string]int)
+	for _, file := range metadata {
+		refs[file.BlobKey()]++
 	}
 
-	// 确保返回空切片而不是nil
-	if metadata == nil {
-		return []*FileMetadata{}, nil
+	s := &FileService{
+		metadata:      metadataStore,
+		storage:       blobStorage,
+		refs:          refs,
+		thumbnails:    make(map[string]map[string]int64),
+		thumbnailSize: thumbnailSize,
+		previewSize:   previewSize,
+		types:         types,
+		reservedBy:    make(map[string]int64),
+		downloads:     make(map[string]int),
+		resumes:       make(map[string][]resumePoint),
+	}
+	if err := s.scanStorage(metadata); err != nil {
+		return nil, err
 	}
 
-	return metadata, nil
+	return s, nil
 }
 
-// WriteMetadata 写入元数据
-func (s *FileService) WriteMetadata(metadata []*FileMetadata) error {
-	data, err := json.MarshalIndent(metadata, "", "  ")
+// AddFile 写入文件内容并添加文件元数据，内容相同的文件共用同一份存储；
+// 文件类型根据内容检测，不在允许范围内时返回ErrFileTypeNotAllowed，此时不会写入任何内容
+func (s *FileService) AddFile(r io.Reader, fileInfo *FileInfo) (*FileMetadata, error) {
+	// 不信任客户端声明的Content-Type，根据内容开头的魔数检测真实类型
+	mime, r, err := sniffReader(r)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !s.types.Permits(mime) {
+		logger.Warnf("File type not allowed: %s, detected: %s, declared: %s", fileInfo.OriginalName, mime.String(), fileInfo.Mimetype)
+		return nil, ErrFileTypeNotAllowed
 	}
 
-	if err := os.WriteFile(s.metadataPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write metadata file: %w", err)
+	// 先写入临时键并计算摘要，此时不持有锁，避免阻塞其他请求
+	tmpKey := tmpKeyPrefix + uuid.New().String()
+	hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(r, hash)}
+	if err := s.storage.Put(tmpKey, counter, fileInfo.Size); err != nil {
+		s.storage.Delete(tmpKey)
+		return nil, fmt.Errorf("failed to save file: %w", err)
 	}
 
-	return nil
-}
+	if fileInfo.Size >= 0 && counter.n != fileInfo.Size {
+		s.storage.Delete(tmpKey)
+		return nil, ErrFileSizeMismatch
+	}
+
+	// 客户端提供了期望的摘要时校验内容是否完整
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if fileInfo.ExpectedDigest != "" && !strings.EqualFold(fileInfo.ExpectedDigest, digest) {
+		s.storage.Delete(tmpKey)
+		return nil, ErrChecksumMismatch
+	}
+	key := digestKeyPrefix + digest
 
-// AddFileMetadata 添加文件元数据
-func (s *FileService) AddFileMetadata(fileInfo *FileInfo) (*FileMetadata, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	metadata, err := s.ReadMetadata()
-	if err != nil {
-		return nil, err
+	// 已有相同内容时丢弃临时内容，否则移动到摘要对应的键；
+	// 已有内容被标记为损坏时用刚校验过的上传内容替换，并取消其他引用的损坏标记
+	shared := s.refs[key] > 0
+	repair := false
+	if shared {
+		if repair, err = s.blobCorrupt(key); err != nil {
+			s.storage.Delete(tmpKey)
+			return nil, err
+		}
+	}
+	if shared && !repair {
+		if err := s.storage.Delete(tmpKey); err != nil {
+			logger.Errorf("Failed to delete duplicate upload %s: %v", tmpKey, err)
+		}
+	} else if err := s.storage.Move(tmpKey, key); err != nil {
+		s.storage.Delete(tmpKey)
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+	if repair {
+		// 缩略图可能是从损坏的内容生成的
+		if err := s.deleteThumbnails(key); err != nil {
+			logger.Errorf("Failed to delete thumbnail of %s: %v", key, err)
+		}
+		if _, err := s.setBlobVerified(key, digest, false); err != nil {
+			logger.Errorf("Failed to clear corrupt flag of %s: %v", key, err)
+		}
+		logger.Infof("Corrupt blob %s replaced by a verified upload", key)
 	}
 
+	now := time.Now().UnixMilli()
 	newFile := &FileMetadata{
-		ID:             uuid.New().String(),
-		Filename:       fileInfo.OriginalName,
-		Size:           fileInfo.Size,
-		Mimetype:       fileInfo.Mimetype,
-		FilePath:       fileInfo.Path,
-		UploadTime:     time.Now().UnixMilli(),
-		LastAccessTime: time.Now().UnixMilli(),
-		DownloadCount:  0,
-		MaxDownloads:   fileInfo.MaxDownloads,
-	}
-
-	metadata = append(metadata, newFile)
-	if err := s.WriteMetadata(metadata); err != nil {
+		ID:               uuid.New().String(),
+		Owner:            fileInfo.Owner,
+		Filename:         fileInfo.OriginalName,
+		Size:             counter.n,
+		Mimetype:         mime.String(),
+		DeclaredMimetype: fileInfo.Mimetype,
+		StorageKey:       key,
+		Digest:           digest,
+		VerifiedAt:       now,
+		UploadTime:       now,
+		LastAccessTime:   now,
+		DownloadCount:    0,
+		MaxDownloads:     fileInfo.MaxDownloads,
+		PasswordHash:     fileInfo.PasswordHash,
+	}
+	if fileInfo.ExpiresIn > 0 {
+		newFile.ExpiresAt = now + fileInfo.ExpiresIn
+	}
+
+	if err := s.metadata.Put(newFile); err != nil {
+		if s.refs[key] == 0 {
+			s.storage.Delete(key)
+		}
 		return nil, err
 	}
+	s.refs[key]++
 
 	return newFile, nil
 }
 
-// GetFileMetadata 获取文件元数据
-func (s *FileService) GetFileMetadata(id string) (*FileMetadata, error) {
+// OpenFile 打开文件内容
+func (s *FileService) OpenFile(file *FileMetadata) (io.ReadCloser, error) {
+	return s.storage.Get(file.BlobKey())
+}
+
+// OpenFileRange 打开文件内容中从offset开始的length字节
+func (s *FileService) OpenFileRange(file *FileMetadata, offset, length int64) (io.ReadCloser, error) {
+	return s.storage.GetRange(file.BlobKey(), offset, length)
+}
+
+// StatFile 获取文件内容信息，内容不存在时返回storage.ErrNotFound
+func (s *FileService) StatFile(file *FileMetadata) (*storage.BlobInfo, error) {
+	return s.storage.Stat(file.BlobKey())
+}
+
+// GetFileMetadata 获取命名空间owner中的文件元数据，其他命名空间的文件视为不存在
+func (s *FileService) GetFileMetadata(owner, id string) (*FileMetadata, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	metadata, err := s.ReadMetadata()
+	file, err := s.metadata.Get(id)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, file := range metadata {
-		if file.ID == id {
-			return file, nil
-		}
+	if file.Owner != owner {
+		return nil, ErrFileNotFound
 	}
 
-	return nil, ErrFileNotFound
+	return file, nil
 }
 
-// GetAllFileMetadata 获取所有文件元数据
-func (s *FileService) GetAllFileMetadata() ([]*FileMetadata, error) {
+// GetAllFileMetadata 获取命名空间owner中的所有文件元数据
+func (s *FileService) GetAllFileMetadata(owner string) ([]*FileMetadata, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.ReadMetadata()
+	metadata, err := s.metadata.List()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*FileMetadata, 0, len(metadata))
+	for _, file := range metadata {
+		if file.Owner == owner {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
 }
 
 // UpdateFileMetadata 更新文件元数据
@@ -155,26 +251,11 @@ func (s *FileService) UpdateFileMetadata(id string, updates map[string]interface
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	metadata, err := s.ReadMetadata()
+	file, err := s.metadata.Get(id)
 	if err != nil {
 		return nil, err
 	}
 
-	var file *FileMetadata
-	var index int
-
-	for i, f := range metadata {
-		if f.ID == id {
-			file = f
-			index = i
-			break
-		}
-	}
-
-	if file == nil {
-		return nil, ErrFileNotFound
-	}
-
 	// 更新字段
 	for key, value := range updates {
 		switch key {
@@ -192,8 +273,7 @@ func (s *FileService) UpdateFileMetadata(id string, updates map[string]interface
 	// 更新最后访问时间
 	file.LastAccessTime = time.Now().UnixMilli()
 
-	metadata[index] = file
-	if err := s.WriteMetadata(metadata); err != nil {
+	if err := s.metadata.Put(file); err != nil {
 		return nil, err
 	}
 
@@ -205,103 +285,310 @@ func (s *FileService) DeleteFile(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	metadata, err := s.ReadMetadata()
+	file, err := s.metadata.Get(id)
 	if err != nil {
 		return err
 	}
 
-	var file *FileMetadata
-	var index int
+	// 先删除元数据再释放引用，中途失败时最多留下无主的内容（下次启动时清理），不会留下指向已删除内容的元数据
+	if err := s.metadata.Delete(id); err != nil {
+		return err
+	}
+
+	// 最后一个引用被删除时才删除实际文件
+	key := file.BlobKey()
+	if s.release(key) == 0 {
+		if err := s.deleteBlob(key); err != nil {
+			logger.Errorf("Failed to delete file %s: %v", file.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// CleanupExpiredFiles 清理过期文件，文件设置了过期时间时按过期时间，否则按上传时间加maxAge判断
+func (s *FileService) CleanupExpiredFiles(maxAge int64) (int, error) {
+	now := time.Now().UnixMilli()
+	deleted, err := s.deleteFiles(func(file *FileMetadata) bool {
+		return file.Expired(now, maxAge)
+	})
+
+	return len(deleted), err
+}
+
+// DeleteNamespaceFiles 删除所属命名空间满足match的所有文件（例如已关闭的房间），返回被删除的文件
+func (s *FileService) DeleteNamespaceFiles(match func(owner string) bool) ([]*FileMetadata, error) {
+	return s.deleteFiles(func(file *FileMetadata) bool {
+		return match(file.Owner)
+	})
+}
+
+// deleteFiles 删除满足match的所有文件，返回被删除的文件
+func (s *FileService) deleteFiles(match func(file *FileMetadata) bool) ([]*FileMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, err := s.metadata.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []*FileMetadata
+	var ids []string
+	for _, file := range metadata {
+		if match(file) {
+			deleted = append(deleted, file)
+			ids = append(ids, file.ID)
+		}
+	}
+
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	if err := s.metadata.Delete(ids...); err != nil {
+		return nil, err
+	}
 
-	for i, f := range metadata {
-		if f.ID == id {
-			file = f
-			index = i
-			break
+	// 元数据删除后释放引用，最后一个引用被删除时删除实际文件
+	for _, file := range deleted {
+		key := file.BlobKey()
+		if s.release(key) > 0 {
+			continue
+		}
+		if err := s.deleteBlob(key); err != nil {
+			// 记录错误但继续执行
+			logger.Errorf("Failed to delete file %s: %v", file.ID, err)
 		}
 	}
 
-	if file == nil {
-		return ErrFileNotFound
+	return deleted, nil
+}
+
+// scanStorage 启动时对照元数据检查存储：写入内容后、保存元数据前中断留下的无主内容和临时内容直接删除，
+// 缓存的缩略图和预览图片记录下来用于统计用量（见loadThumbnail）；旧版本命名的内容不在此处理，
+// 已记录摘要的旧版本文件对应的内容可能是中断的迁移移动过去的，同样保留
+func (s *FileService) scanStorage(metadata []*FileMetadata) error {
+	migrating := make(map[string]bool)
+	for _, file := range metadata {
+		if file.Digest != "" {
+			migrating[digestKeyPrefix+file.Digest] = true
+		}
 	}
 
-	// 删除实际文件
-	if err := os.Remove(file.FilePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("failed to delete file: %w", err)
+	blobs, err := s.storage.List("")
+	if err != nil {
+		return fmt.Errorf("failed to list storage: %w", err)
 	}
 
-	// 删除元数据
-	metadata = append(metadata[:index], metadata[index+1:]...)
-	if err := s.WriteMetadata(metadata); err != nil {
+	for _, blob := range blobs {
+		if s.refs[blob.Key] > 0 || migrating[blob.Key] {
+			continue
+		}
+		if _, _, ok := parseThumbnailKey(blob.Key); ok {
+			s.loadThumbnail(blob)
+			continue
+		}
+		if !strings.HasPrefix(blob.Key, digestKeyPrefix) && !strings.HasPrefix(blob.Key, tmpKeyPrefix) {
+			continue
+		}
+
+		if err := s.storage.Delete(blob.Key); err != nil {
+			logger.Errorf("Failed to delete unreferenced blob %s: %v", blob.Key, err)
+			continue
+		}
+		logger.Infof("Deleted unreferenced blob %s", blob.Key)
+	}
+
+	return nil
+}
+
+// deleteBlob 删除存储内容及其缓存的缩略图
+func (s *FileService) deleteBlob(key string) error {
+	if err := s.storage.Delete(key); err != nil {
 		return err
 	}
 
+	if err := s.deleteThumbnails(key); err != nil {
+		logger.Errorf("Failed to delete thumbnail of %s: %v", key, err)
+	}
+
 	return nil
 }
 
-// CleanupExpiredFiles 清理过期文件
-func (s *FileService) CleanupExpiredFiles(maxAge int64) (int, error) {
+// release 释放存储键的一个引用，返回剩余的引用数
+func (s *FileService) release(key string) int {
+	s.refs[key]--
+	if s.refs[key] <= 0 {
+		delete(s.refs, key)
+		return 0
+	}
+
+	return s.refs[key]
+}
+
+// CheckStorage 检查写入size字节后是否超过限制：总存储（相同内容只计算一次）超过maxStorage时返回ErrStorageExceeded，
+// 命名空间owner的用量（其中所有文件大小之和）超过quota时返回ErrQuotaExceeded，quota为0表示不限制命名空间用量；
+// 两者都包含正在进行的上传预留的空间和缓存的缩略图、预览图片
+func (s *FileService) CheckStorage(owner string, size, maxStorage, quota int64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.checkStorage(owner, size, maxStorage, quota)
+}
+
+// checkStorage 检查存储限制，调用方需持有锁
+func (s *FileService) checkStorage(owner string, size, maxStorage, quota int64) error {
+	usage, err := s.storageUsage()
+	if err != nil {
+		return err
+	}
+	if usage.PhysicalSize+usage.CacheSize+usage.Reserved+size > maxStorage {
+		return ErrStorageExceeded
+	}
+
+	if quota > 0 {
+		used, err := s.namespaceUsage(owner)
+		if err != nil {
+			return err
+		}
+		if used+size > quota {
+			return ErrQuotaExceeded
+		}
+	}
+
+	return nil
+}
+
+// ReserveStorage 为命名空间owner即将写入的size字节预留存储空间，超过限制时返回ErrStorageExceeded或ErrQuotaExceeded（见CheckStorage）；
+// 预留在写入前完成，并发上传不会同时通过检查后一起超出限制，写入结束（无论成功与否）后必须调用返回的release
+func (s *FileService) ReserveStorage(owner string, size, maxStorage, quota int64) (release func(), err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	metadata, err := s.ReadMetadata()
+	if err := s.checkStorage(owner, size, maxStorage, quota); err != nil {
+		return nil, err
+	}
+	s.reserved += size
+	s.reservedBy[owner] += size
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.reserved -= size
+			s.reservedBy[owner] -= size
+			if s.reservedBy[owner] <= 0 {
+				delete(s.reservedBy, owner)
+			}
+			s.mu.Unlock()
+		})
+	}, nil
+}
+
+// namespaceUsage 统计命名空间的存储用量（文件及其缓存的缩略图、预览图片的大小之和加上预留的空间），调用方需持有锁；
+// 不同命名空间上传的相同内容在各自的命名空间中分别计算
+func (s *FileService) namespaceUsage(owner string) (int64, error) {
+	metadata, err := s.metadata.List()
 	if err != nil {
 		return 0, err
 	}
 
-	now := time.Now().UnixMilli()
-	var deletedCount int
-	var remainingMetadata []*FileMetadata
-
+	used := s.reservedBy[owner]
 	for _, file := range metadata {
-		if now-file.UploadTime > maxAge {
-			// 删除实际文件
-			if err := os.Remove(file.FilePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
-				// 记录错误但继续执行
-				fmt.Printf("Failed to delete expired file %s: %v\n", file.ID, err)
-			}
-			deletedCount++
-		} else {
-			remainingMetadata = append(remainingMetadata, file)
+		if file.Owner == owner {
+			used += file.Size + s.thumbnailUsage(file.BlobKey())
 		}
 	}
 
-	if err := s.WriteMetadata(remainingMetadata); err != nil {
-		return 0, err
-	}
-
-	return deletedCount, nil
+	return used, nil
 }
 
-// CheckTotalStorage 检查总存储大小
-func (s *FileService) CheckTotalStorage() (int64, error) {
+// GetStorageUsage 获取存储用量，逻辑用量为所有文件大小之和，物理用量为去重后实际占用的大小，
+// 缓存用量为缩略图和预览图片占用的大小
+func (s *FileService) GetStorageUsage() (*StorageUsage, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	metadata, err := s.ReadMetadata()
+	return s.storageUsage()
+}
+
+// storageUsage 统计存储用量，调用方需持有锁
+func (s *FileService) storageUsage() (*StorageUsage, error) {
+	metadata, err := s.metadata.List()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	var totalSize int64
+	usage := &StorageUsage{Reserved: s.reserved}
+	for key := range s.thumbnails {
+		usage.CacheSize += s.thumbnailUsage(key)
+	}
+	seen := make(map[string]bool)
 	for _, file := range metadata {
-		totalSize += file.Size
+		usage.FileCount++
+		usage.LogicalSize += file.Size
+
+		key := file.BlobKey()
+		if !seen[key] {
+			seen[key] = true
+			usage.BlobCount++
+			usage.PhysicalSize += file.Size
+		}
 	}
 
-	return totalSize, nil
+	return usage, nil
+}
+
+// StorageUsage 存储用量
+type StorageUsage struct {
+	FileCount    int   `json:"fileCount"`
+	BlobCount    int   `json:"blobCount"`
+	LogicalSize  int64 `json:"logicalSize"`
+	PhysicalSize int64 `json:"physicalSize"`
+	CacheSize    int64 `json:"cacheSize"`
+	Reserved     int64 `json:"reserved"`
+}
+
+// countingReader 统计读取字节数的Reader
+type countingReader struct {
+	r io.Reader
+	n int64
 }
 
-// FileInfo 文件信息
+// Read 读取数据
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// FileInfo 文件信息，Owner为上传者所属的命名空间
 type FileInfo struct {
+	Owner        string
 	OriginalName string
 	Size         int64
-	Mimetype     string
-	Path         string
-	MaxDownloads int
+	// Mimetype 客户端声明的类型
+	Mimetype       string
+	ExpectedDigest string
+	SharePolicy
 }
 
+// 存储键前缀
+const (
+	// digestKeyPrefix 按SHA-256摘要存储的内容
+	digestKeyPrefix = "sha256/"
+	// tmpKeyPrefix 计算摘要前的临时内容
+	tmpKeyPrefix = ".tmp/"
+)
+
 // 错误定义
 var (
 	ErrFileNotFound        = errors.New("file not found")
 	ErrMaxDownloadsReached = errors.New("maximum download limit reached")
+	ErrFileSizeMismatch    = errors.New("file size does not match content length")
+	ErrChecksumMismatch    = errors.New("file checksum mismatch")
+	ErrFileTypeNotAllowed  = errors.New("file type not allowed")
+	ErrStorageExceeded     = errors.New("total storage limit exceeded")
+	ErrQuotaExceeded       = errors.New("namespace storage quota exceeded")
 )
@@ -0,0 +1,137 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud-clipboard/internal/storage"
+)
+
+func TestNewFileServiceScansStorage(t *testing.T) {
+	dir := t.TempDir()
+	blobStorage, err := storage.NewLocalStorage(filepath.Join(dir, "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	metadataStore, err := NewJSONMetadataStore(filepath.Join(dir, "metadata.json"), 0)
+	if err != nil {
+		t.Fatalf("NewJSONMetadataStore: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("legacy"))
+	digest := hex.EncodeToString(sum[:])
+	files := []*FileMetadata{
+		{ID: "current", StorageKey: "sha256/current", Size: 7},
+		// 迁移在移动内容后、更新元数据前中断
+		{ID: "legacy", FilePath: "uploads/123-legacy.txt", Digest: digest, Size: 6},
+	}
+	for _, file := range files {
+		if err := metadataStore.Put(file); err != nil {
+			t.Fatalf("Put metadata: %v", err)
+		}
+	}
+
+	blobs := map[string]string{
+		"sha256/current":   "current",
+		"sha256/" + digest: "legacy",
+		"sha256/orphan":    "orphan",
+		".tmp/upload":      "partial",
+		"456-unknown.txt":  "unknown",
+	}
+	for key, content := range blobs {
+		if err := blobStorage.Put(key, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	s, err := NewFileService(blobStorage, metadataStore, 0, 0, TypePolicy{})
+	if err != nil {
+		t.Fatalf("NewFileService: %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		kept bool
+	}{
+		{"sha256/current", true},
+		{"sha256/" + digest, true},
+		{"sha256/orphan", false},
+		{".tmp/upload", false},
+		// 旧版本命名的内容不由扫描处理
+		{"456-unknown.txt", true},
+	}
+	for _, tt := range tests {
+		_, err := blobStorage.Stat(tt.key)
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("%s kept = %v, want %v (err = %v)", tt.key, kept, tt.kept, err)
+		}
+	}
+
+	// 重新迁移时根据记录的摘要找到已移动的内容
+	migrated, err := s.MigrateLegacyBlobs()
+	if err != nil {
+		t.Fatalf("MigrateLegacyBlobs: %v", err)
+	}
+	if migrated != 1 {
+		t.Fatalf("migrated = %d, want 1", migrated)
+	}
+	file, err := s.GetFileMetadata("", "legacy")
+	if err != nil {
+		t.Fatalf("GetFileMetadata: %v", err)
+	}
+	if file.BlobKey() != "sha256/"+digest {
+		t.Fatalf("blob key = %s, want sha256/%s", file.BlobKey(), digest)
+	}
+	if s.refs["sha256/"+digest] != 1 || s.refs["123-legacy.txt"] != 0 {
+		t.Fatalf("refs = %v", s.refs)
+	}
+}
+
+func TestAddFileRepairsCorruptBlob(t *testing.T) {
+	s := newTestService(t)
+	original := addTestFile(t, s, "0123456789", 0)
+
+	// 存储中的内容损坏后被校验任务标记
+	if err := s.storage.Put(original.BlobKey(), strings.NewReader("01234XXXXX"), 10); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	result, err := s.ScrubFiles()
+	if err != nil {
+		t.Fatalf("ScrubFiles: %v", err)
+	}
+	if len(result.Corrupted) != 1 {
+		t.Fatalf("corrupted = %v, want [%s]", result.Corrupted, original.ID)
+	}
+
+	// 重新上传相同内容时替换损坏的内容
+	uploaded := addTestFile(t, s, "0123456789", 0)
+	if uploaded.BlobKey() != original.BlobKey() {
+		t.Fatalf("blob key = %s, want %s", uploaded.BlobKey(), original.BlobKey())
+	}
+	for _, id := range []string{original.ID, uploaded.ID} {
+		file, err := s.GetFileMetadata("", id)
+		if err != nil {
+			t.Fatalf("GetFileMetadata(%s): %v", id, err)
+		}
+		if file.Corrupt {
+			t.Errorf("file %s still marked corrupt", id)
+		}
+
+		r, err := s.OpenFile(file)
+		if err != nil {
+			t.Fatalf("OpenFile(%s): %v", id, err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != "0123456789" {
+			t.Errorf("file %s content = %q, want %q", id, data, "0123456789")
+		}
+	}
+	if s.refs[original.BlobKey()] != 2 {
+		t.Fatalf("refs = %d, want 2", s.refs[original.BlobKey()])
+	}
+}
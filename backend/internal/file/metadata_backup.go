@@ -0,0 +1,175 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cloud-clipboard/internal/logger"
+)
+
+// backupPath 第index个备份的路径，编号越小越新
+func backupPath(path string, index int) string {
+	return fmt.Sprintf("%s.bak.%d", path, index)
+}
+
+// writeFileAtomic 先写同目录下的临时文件并落盘，再重命名覆盖目标文件，
+// 崩溃时目标文件要么是旧内容要么是新内容，不会出现写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to chmod temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir 落盘目录项，保证重命名在断电后仍然生效
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	// 部分平台不支持对目录fsync，忽略该错误
+	d.Sync()
+	return nil
+}
+
+// rotateBackups 将当前文件保存为最新的备份，并只保留最近count个备份
+func rotateBackups(path string, count int) error {
+	if count <= 0 {
+		return nil
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	// 依次后移旧备份，最旧的被覆盖
+	for i := count - 1; i >= 1; i-- {
+		if err := os.Rename(backupPath(path, i), backupPath(path, i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to rotate metadata backup: %w", err)
+		}
+	}
+
+	// 优先使用硬链接，避免复制整个文件
+	latest := backupPath(path, 1)
+	if err := os.Link(path, latest); err != nil {
+		if err := copyFile(path, latest); err != nil {
+			return fmt.Errorf("failed to create metadata backup: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// copyFile 复制文件
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// readMetadataWithBackups 读取元数据文件，主文件缺失或损坏时依次尝试备份，
+// 返回读取到的元数据及其来源路径
+func readMetadataWithBackups(path string, backups int) ([]*FileMetadata, string, error) {
+	metadata, err := readMetadataFile(path)
+	if err == nil {
+		return metadata, path, nil
+	}
+	primaryErr := err
+
+	for i := 1; i <= backups; i++ {
+		candidate := backupPath(path, i)
+		metadata, err := readMetadataFile(candidate)
+		if err == nil {
+			return metadata, candidate, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Warnf("Skipping corrupt metadata backup %s: %v", candidate, err)
+		}
+	}
+
+	return nil, "", primaryErr
+}
+
+// recoverMetadataFile 启动时检查元数据文件，损坏时从最近的有效备份恢复，
+// 没有可用备份时将损坏的文件移到一旁并以空列表启动，避免整个文件接口不可用
+func recoverMetadataFile(path string, backups int) error {
+	metadata, source, err := readMetadataWithBackups(path, backups)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// 全新部署，创建空的元数据文件
+			return writeFileAtomic(path, []byte("[]"))
+		}
+
+		corruptPath := fmt.Sprintf("%s.corrupt-%d", path, time.Now().Unix())
+		if renameErr := os.Rename(path, corruptPath); renameErr != nil {
+			return fmt.Errorf("failed to move corrupt metadata file: %w", renameErr)
+		}
+		logger.Errorf("Metadata file %s is corrupt and no valid backup exists, moved to %s: %v", path, corruptPath, err)
+		return writeFileAtomic(path, []byte("[]"))
+	}
+
+	if source == path {
+		return nil
+	}
+
+	// 保留损坏的主文件以便排查
+	if _, statErr := os.Stat(path); statErr == nil {
+		corruptPath := fmt.Sprintf("%s.corrupt-%d", path, time.Now().Unix())
+		if err := os.Rename(path, corruptPath); err != nil {
+			return fmt.Errorf("failed to move corrupt metadata file: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+
+	logger.Warnf("Metadata file %s was missing or corrupt, recovered %d records from %s", path, len(metadata), source)
+	return nil
+}
@@ -0,0 +1,158 @@
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 元数据存储类型
+const (
+	MetadataStoreBolt = "bolt"
+	MetadataStoreJSON = "json"
+)
+
+// MetadataStore 文件元数据存储接口，调用方负责并发控制
+type MetadataStore interface {
+	// List 获取所有文件元数据（按上传时间排序）
+	List() ([]*FileMetadata, error)
+	// Get 获取文件元数据，不存在时返回ErrFileNotFound
+	Get(id string) (*FileMetadata, error)
+	// Put 添加或更新文件元数据
+	Put(file *FileMetadata) error
+	// Delete 删除文件元数据
+	Delete(ids ...string) error
+	// Close 关闭存储
+	Close() error
+}
+
+// jsonMetadataStore 基于单个JSON文件的元数据存储，每次读写整个文件
+type jsonMetadataStore struct {
+	path    string
+	backups int
+}
+
+// NewJSONMetadataStore 创建基于JSON文件的元数据存储，并保留最近backups个版本的备份，
+// 元数据文件缺失或损坏时自动从备份恢复
+func NewJSONMetadataStore(path string, backups int) (MetadataStore, error) {
+	// 确保元数据文件目录存在
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	// 确保元数据文件存在且有效
+	if err := recoverMetadataFile(path, backups); err != nil {
+		return nil, fmt.Errorf("failed to recover metadata file: %w", err)
+	}
+
+	return &jsonMetadataStore{path: path, backups: backups}, nil
+}
+
+// List 获取所有文件元数据
+func (s *jsonMetadataStore) List() ([]*FileMetadata, error) {
+	return readMetadataFile(s.path)
+}
+
+// Get 获取文件元数据
+func (s *jsonMetadataStore) Get(id string) (*FileMetadata, error) {
+	metadata, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range metadata {
+		if file.ID == id {
+			return file, nil
+		}
+	}
+
+	return nil, ErrFileNotFound
+}
+
+// Put 添加或更新文件元数据
+func (s *jsonMetadataStore) Put(file *FileMetadata) error {
+	metadata, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, f := range metadata {
+		if f.ID == file.ID {
+			metadata[i] = file
+			found = true
+			break
+		}
+	}
+	if !found {
+		metadata = append(metadata, file)
+	}
+
+	return s.write(metadata)
+}
+
+// Delete 删除文件元数据
+func (s *jsonMetadataStore) Delete(ids ...string) error {
+	metadata, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+
+	remaining := make([]*FileMetadata, 0, len(metadata))
+	for _, file := range metadata {
+		if !deleted[file.ID] {
+			remaining = append(remaining, file)
+		}
+	}
+
+	return s.write(remaining)
+}
+
+// Close 关闭存储
+func (s *jsonMetadataStore) Close() error {
+	return nil
+}
+
+// write 写入元数据，写入前将当前版本保存为备份
+func (s *jsonMetadataStore) write(metadata []*FileMetadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	if err := rotateBackups(s.path, s.backups); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+
+	return nil
+}
+
+// readMetadataFile 读取JSON元数据文件
+func readMetadataFile(path string) ([]*FileMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata file: %w", err)
+	}
+
+	var metadata []*FileMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+
+	// 确保返回空切片而不是nil
+	if metadata == nil {
+		return []*FileMetadata{}, nil
+	}
+
+	return metadata, nil
+}
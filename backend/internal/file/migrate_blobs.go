@@ -0,0 +1,105 @@
+package file
+
+import (
+	"strings"
+
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/storage"
+)
+
+// MigrateLegacyBlobs 将旧版本以"<时间戳>-<原始文件名>"命名的存储内容移动到按摘要命名的键，
+// 存储中不再出现原始文件名；已有相同内容时直接共用，返回迁移的文件数量。
+// 摘要与记录不一致的内容保持原样，由校验任务标记为已损坏
+func (s *FileService) MigrateLegacyBlobs() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, err := s.metadata.List()
+	if err != nil {
+		return 0, err
+	}
+
+	// 按旧的存储键分组，同一份内容只读取一次
+	var legacyKeys []string
+	groups := make(map[string][]*FileMetadata)
+	for _, file := range metadata {
+		key := file.BlobKey()
+		if strings.HasPrefix(key, digestKeyPrefix) {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			legacyKeys = append(legacyKeys, key)
+		}
+		groups[key] = append(groups[key], file)
+	}
+
+	migrated := 0
+	for _, oldKey := range legacyKeys {
+		files := groups[oldKey]
+
+		digest, _, err := s.hashBlob(oldKey)
+		moved := false
+		if err == storage.ErrNotFound {
+			// 上次迁移在移动内容后、更新全部元数据前中断时，内容已经在摘要对应的键下，只需更新元数据；
+			// 其他情况的内容缺失由下载时的检查负责清理
+			if files[0].Digest == "" {
+				continue
+			}
+			if _, err := s.storage.Stat(digestKeyPrefix + files[0].Digest); err != nil {
+				continue
+			}
+			digest, moved = files[0].Digest, true
+		} else if err != nil {
+			logger.Errorf("Failed to read legacy file %s: %v", files[0].ID, err)
+			continue
+		}
+		if files[0].Digest != "" && files[0].Digest != digest {
+			logger.Warnf("Legacy file %s does not match its recorded digest, leaving it in place", files[0].ID)
+			continue
+		}
+
+		key := digestKeyPrefix + digest
+		shared := s.refs[key] > 0
+		if !shared && !moved {
+			// 移动前先记录摘要，中断后重新迁移时可以根据摘要找到已移动的内容
+			for _, file := range files {
+				if file.Digest == "" {
+					file.Digest = digest
+					if err := s.metadata.Put(file); err != nil {
+						return migrated, err
+					}
+				}
+			}
+			if err := s.storage.Move(oldKey, key); err != nil {
+				logger.Errorf("Failed to move legacy file %s: %v", files[0].ID, err)
+				continue
+			}
+		}
+
+		// 逐个更新引用计数，中途失败时内存中的计数仍与元数据一致
+		for _, file := range files {
+			file.StorageKey = key
+			file.FilePath = ""
+			if file.Digest == "" {
+				file.Digest = digest
+			}
+			if err := s.metadata.Put(file); err != nil {
+				return migrated, err
+			}
+			s.refs[key]++
+			s.release(oldKey)
+			migrated++
+		}
+
+		// 已有相同内容时删除旧内容，否则只删除旧键下缓存的缩略图
+		if shared {
+			if err := s.deleteBlob(oldKey); err != nil {
+				logger.Errorf("Failed to delete duplicate legacy file %s: %v", oldKey, err)
+			}
+		} else if err := s.deleteThumbnails(oldKey); err != nil {
+			logger.Errorf("Failed to delete thumbnail of %s: %v", oldKey, err)
+		}
+	}
+
+	return migrated, nil
+}
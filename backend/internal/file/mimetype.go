@@ -0,0 +1,63 @@
+package file
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// sniffLen 检测文件类型时读取的最大字节数，与mimetype库的默认读取上限一致
+const sniffLen = 3072
+
+// TypePolicy 上传文件类型的允许/禁止列表，按检测出的真实类型匹配，
+// 支持完整类型（application/pdf）和通配（image/*），匹配时也会检查父类型，
+// 例如禁止application/zip同时会禁止基于zip的docx、jar等格式；
+// Allowed为空表示允许除Denied以外的所有类型，同时匹配两个列表时以Denied为准
+type TypePolicy struct {
+	Allowed []string
+	Denied  []string
+}
+
+// Permits 判断是否允许上传该类型的文件
+func (p TypePolicy) Permits(mime *mimetype.MIME) bool {
+	if matchTypes(p.Denied, mime) {
+		return false
+	}
+	return len(p.Allowed) == 0 || matchTypes(p.Allowed, mime)
+}
+
+// matchTypes 判断类型或其任一父类型是否匹配列表中的某一项
+func matchTypes(patterns []string, mime *mimetype.MIME) bool {
+	for m := mime; m != nil; m = m.Parent() {
+		for _, pattern := range patterns {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+			if strings.HasSuffix(pattern, "/*") {
+				if strings.HasPrefix(baseType(m.String()), strings.TrimSuffix(pattern, "*")) {
+					return true
+				}
+			} else if m.Is(pattern) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// baseType 去掉MIME类型中的参数部分，例如text/plain; charset=utf-8返回text/plain
+func baseType(mimetype string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(mimetype, ";")[0]))
+}
+
+// sniffReader 根据内容开头的魔数检测文件类型，返回的Reader仍然包含完整内容
+func sniffReader(r io.Reader) (*mimetype.MIME, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	return mimetype.Detect(head), br, nil
+}
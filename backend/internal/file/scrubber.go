@@ -0,0 +1,162 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/storage"
+)
+
+// ScrubResult 文件完整性校验结果
+type ScrubResult struct {
+	Checked   int      `json:"checked"`
+	Corrupted []string `json:"corrupted"`
+	Repaired  []string `json:"repaired"`
+
+	owners map[string]string
+}
+
+// CorruptedByOwner 按所属命名空间分组的已损坏文件ID
+func (r *ScrubResult) CorruptedByOwner() map[string][]string {
+	groups := make(map[string][]string)
+	for _, id := range r.Corrupted {
+		owner := r.owners[id]
+		groups[owner] = append(groups[owner], id)
+	}
+
+	return groups
+}
+
+// ScrubFiles 重新读取所有存储内容并校验SHA-256摘要，摘要或大小不一致的文件标记为已损坏，
+// 之前标记为损坏但重新校验通过的文件（例如已从备份恢复）取消标记；
+// 旧版本没有摘要的文件在首次校验时记录摘要
+func (s *FileService) ScrubFiles() (*ScrubResult, error) {
+	s.mu.RLock()
+	metadata, err := s.metadata.List()
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// 相同内容只校验一次
+	blobs := make(map[string]*FileMetadata)
+	for _, file := range metadata {
+		if _, ok := blobs[file.BlobKey()]; !ok {
+			blobs[file.BlobKey()] = file
+		}
+	}
+
+	result := &ScrubResult{Corrupted: []string{}, Repaired: []string{}, owners: make(map[string]string)}
+	for key, file := range blobs {
+		// 读取内容时不持有锁，避免长时间阻塞上传和下载
+		digest, size, err := s.hashBlob(key)
+		if err == storage.ErrNotFound {
+			// 内容缺失由下载时的检查负责清理
+			continue
+		}
+		if err != nil {
+			logger.Errorf("Failed to verify file %s: %v", file.ID, err)
+			continue
+		}
+		result.Checked++
+
+		expected := file.Digest
+		if expected == "" {
+			expected = digest
+		}
+		corrupt := digest != expected || size != file.Size
+
+		files, err := s.markBlob(key, digest, corrupt)
+		if err != nil {
+			logger.Errorf("Failed to update verification result of file %s: %v", file.ID, err)
+			continue
+		}
+		ids := make([]string, 0, len(files))
+		for _, f := range files {
+			ids = append(ids, f.ID)
+			result.owners[f.ID] = f.Owner
+		}
+
+		if corrupt {
+			logger.Warnf("File content corrupted: key %s, expected digest %s size %d, got digest %s size %d", key, expected, file.Size, digest, size)
+			result.Corrupted = append(result.Corrupted, ids...)
+		} else if file.Corrupt {
+			result.Repaired = append(result.Repaired, ids...)
+		}
+	}
+
+	return result, nil
+}
+
+// hashBlob 计算存储内容的SHA-256摘要和大小
+func (s *FileService) hashBlob(key string) (string, int64, error) {
+	src, err := s.storage.Get(key)
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, src)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// markBlob 更新引用该存储键的所有文件的校验结果，返回这些文件
+func (s *FileService) markBlob(key, digest string, corrupt bool) ([]*FileMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.setBlobVerified(key, digest, corrupt)
+}
+
+// blobCorrupt 引用该存储键的文件中是否有被标记为损坏的，调用方需持有锁
+func (s *FileService) blobCorrupt(key string) (bool, error) {
+	metadata, err := s.metadata.List()
+	if err != nil {
+		return false, err
+	}
+
+	for _, file := range metadata {
+		if file.BlobKey() == key && file.Corrupt {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// setBlobVerified 记录引用该存储键的所有文件的校验结果，返回这些文件，调用方需持有锁
+func (s *FileService) setBlobVerified(key, digest string, corrupt bool) ([]*FileMetadata, error) {
+	metadata, err := s.metadata.List()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	var files []*FileMetadata
+	for _, file := range metadata {
+		if file.BlobKey() != key {
+			continue
+		}
+
+		if file.Digest == "" {
+			file.Digest = digest
+		}
+		file.Corrupt = corrupt
+		file.VerifiedAt = now
+		if err := s.metadata.Put(file); err != nil {
+			return files, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,58 @@
+package file
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SharePolicy 上传者设置的分享策略
+type SharePolicy struct {
+	// ExpiresIn 有效期（毫秒），从上传完成时开始计算，0表示按全局的maxAge过期
+	ExpiresIn int64 `json:"expiresIn,omitempty"`
+	// MaxDownloads 最大下载次数，0表示不限制
+	MaxDownloads int `json:"maxDownloads"`
+	// PasswordHash 访问密码的bcrypt哈希，为空表示不需要密码
+	PasswordHash string `json:"passwordHash,omitempty"`
+}
+
+// MaxPasswordLength 访问密码的最大字节数，bcrypt只使用前72字节
+const MaxPasswordLength = 72
+
+// HashPassword 计算访问密码的哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// Protected 文件是否设置了访问密码
+func (m *FileMetadata) Protected() bool {
+	return m.PasswordHash != ""
+}
+
+// CheckPassword 校验访问密码，未设置密码时总是通过
+func (m *FileMetadata) CheckPassword(password string) bool {
+	if !m.Protected() {
+		return true
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(m.PasswordHash), []byte(password)) == nil
+}
+
+// Expired 判断文件在now时是否已过期，旧版本没有过期时间的文件按上传时间加maxAge计算
+func (m *FileMetadata) Expired(now, maxAge int64) bool {
+	if m.ExpiresAt > 0 {
+		return now >= m.ExpiresAt
+	}
+
+	return now-m.UploadTime > maxAge
+}
+
+// downloadLimitReached 下载次数是否已用完，MaxDownloads为0表示不限制
+func (m *FileMetadata) downloadLimitReached() bool {
+	return m.MaxDownloads > 0 && m.DownloadCount >= m.MaxDownloads
+}
@@ -0,0 +1,149 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/storage"
+	"cloud-clipboard/internal/thumbnail"
+)
+
+// GetThumbnail 获取文件缩略图及其MIME类型，首次访问时生成并缓存在文件内容旁边，
+// 相同内容的文件共用同一份缩略图
+func (s *FileService) GetThumbnail(file *FileMetadata) ([]byte, string, error) {
+	return s.scaledImage(file, s.thumbnailSize)
+}
+
+// GetPreviewImage 获取用于内联预览的图片及其MIME类型，长边不超过previewSize，缓存方式与缩略图相同；
+// 预览不计入下载次数，因此不返回原图
+func (s *FileService) GetPreviewImage(file *FileMetadata) ([]byte, string, error) {
+	return s.scaledImage(file, s.previewSize)
+}
+
+// scaledImage 获取长边不超过size的图片，首次访问时生成并缓存
+func (s *FileService) scaledImage(file *FileMetadata, size int) ([]byte, string, error) {
+	key := file.BlobKey()
+	thumbKey := s.thumbnailKey(key, size)
+
+	// 优先读取缓存
+	cached, err := s.storage.Get(thumbKey)
+	if err == nil {
+		defer cached.Close()
+		data, err := io.ReadAll(cached)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read thumbnail: %w", err)
+		}
+		return data, http.DetectContentType(data), nil
+	}
+	if err != storage.ErrNotFound {
+		return nil, "", err
+	}
+
+	src, err := s.storage.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+
+	data, contentType, err := thumbnail.Generate(src, size)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 内容在生成期间被删除时不再缓存，避免留下无主的缩略图
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refs[key] > 0 {
+		if err := s.storage.Put(thumbKey, bytes.NewReader(data), int64(len(data))); err != nil {
+			logger.Errorf("Failed to cache thumbnail of %s: %v", key, err)
+		} else {
+			s.addThumbnail(key, thumbKey, int64(len(data)))
+		}
+	}
+
+	return data, contentType, nil
+}
+
+// ThumbnailSize 缩略图长边的最大像素数
+func (s *FileService) ThumbnailSize() int {
+	return s.thumbnailSize
+}
+
+// PreviewSize 预览图片长边的最大像素数
+func (s *FileService) PreviewSize() int {
+	return s.previewSize
+}
+
+// thumbnailKey 尺寸为size的缩略图在存储中的键，包含尺寸以便修改配置后重新生成
+func (s *FileService) thumbnailKey(key string, size int) string {
+	return fmt.Sprintf("%s.thumb-%d", key, size)
+}
+
+// parseThumbnailKey 解析缩略图在存储中的键，返回对应的存储键和尺寸
+func parseThumbnailKey(thumbKey string) (string, int, bool) {
+	i := strings.LastIndex(thumbKey, ".thumb-")
+	if i <= 0 {
+		return "", 0, false
+	}
+	size, err := strconv.Atoi(thumbKey[i+len(".thumb-"):])
+	if err != nil || size <= 0 {
+		return "", 0, false
+	}
+
+	return thumbKey[:i], size, true
+}
+
+// loadThumbnail 记录启动时在存储中找到的缩略图或预览图片，用于统计用量和删除内容时一并删除；
+// 内容已不存在或尺寸与当前配置不一致（修改配置前生成）的缓存不会再被使用，直接删除
+func (s *FileService) loadThumbnail(blob *storage.BlobInfo) {
+	key, size, _ := parseThumbnailKey(blob.Key)
+	if s.refs[key] > 0 && (size == s.thumbnailSize || size == s.previewSize) {
+		s.addThumbnail(key, blob.Key, blob.Size)
+		return
+	}
+
+	if err := s.storage.Delete(blob.Key); err != nil {
+		logger.Errorf("Failed to delete stale thumbnail %s: %v", blob.Key, err)
+		return
+	}
+	logger.Infof("Deleted stale thumbnail %s", blob.Key)
+}
+
+// addThumbnail 记录存储内容key缓存的缩略图，调用方需持有锁
+func (s *FileService) addThumbnail(key, thumbKey string, size int64) {
+	thumbs := s.thumbnails[key]
+	if thumbs == nil {
+		thumbs = make(map[string]int64)
+		s.thumbnails[key] = thumbs
+	}
+	thumbs[thumbKey] = size
+}
+
+// thumbnailUsage 存储内容key缓存的缩略图和预览图片的大小之和，调用方需持有锁
+func (s *FileService) thumbnailUsage(key string) int64 {
+	var used int64
+	for _, size := range s.thumbnails[key] {
+		used += size
+	}
+
+	return used
+}
+
+// deleteThumbnails 删除存储内容缓存的所有尺寸的缩略图和预览图片，调用方需持有锁
+func (s *FileService) deleteThumbnails(key string) error {
+	for thumbKey := range s.thumbnails[key] {
+		if err := s.storage.Delete(thumbKey); err != nil {
+			return err
+		}
+		delete(s.thumbnails[key], thumbKey)
+	}
+	delete(s.thumbnails, key)
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package file
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"cloud-clipboard/internal/storage"
+)
+
+func TestThumbnailsCountedAndCleanedUp(t *testing.T) {
+	dir := t.TempDir()
+	blobStorage, err := storage.NewLocalStorage(filepath.Join(dir, "uploads"))
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	metadataStore, err := NewJSONMetadataStore(filepath.Join(dir, "metadata.json"), 0)
+	if err != nil {
+		t.Fatalf("NewJSONMetadataStore: %v", err)
+	}
+	s, err := NewFileService(blobStorage, metadataStore, 32, 64, TypePolicy{})
+	if err != nil {
+		t.Fatalf("NewFileService: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	file, err := s.AddFile(bytes.NewReader(buf.Bytes()), &FileInfo{
+		OriginalName: "image.png",
+		Size:         int64(buf.Len()),
+	})
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+
+	thumb, _, err := s.GetThumbnail(file)
+	if err != nil {
+		t.Fatalf("GetThumbnail: %v", err)
+	}
+	preview, _, err := s.GetPreviewImage(file)
+	if err != nil {
+		t.Fatalf("GetPreviewImage: %v", err)
+	}
+	cacheSize := int64(len(thumb) + len(preview))
+
+	checkUsage := func(s *FileService) {
+		t.Helper()
+
+		usage, err := s.GetStorageUsage()
+		if err != nil {
+			t.Fatalf("GetStorageUsage: %v", err)
+		}
+		if usage.CacheSize != cacheSize {
+			t.Fatalf("cache size = %d, want %d", usage.CacheSize, cacheSize)
+		}
+		used := file.Size + cacheSize
+		if err := s.CheckStorage("", 1, used, 0); err != ErrStorageExceeded {
+			t.Fatalf("CheckStorage over maxStorage: err = %v, want ErrStorageExceeded", err)
+		}
+		if err := s.CheckStorage("", 1, used+1, used); err != ErrQuotaExceeded {
+			t.Fatalf("CheckStorage over quota: err = %v, want ErrQuotaExceeded", err)
+		}
+		if err := s.CheckStorage("", 1, used+1, used+1); err != nil {
+			t.Fatalf("CheckStorage within limits: %v", err)
+		}
+	}
+	checkUsage(s)
+
+	// 修改尺寸配置前生成的缩略图和内容已被删除的缩略图在启动时删除
+	stale := []string{file.BlobKey() + ".thumb-128", "sha256/missing.thumb-32"}
+	for _, key := range stale {
+		if err := blobStorage.Put(key, bytes.NewReader([]byte("stale")), 5); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	s, err = NewFileService(blobStorage, metadataStore, 32, 64, TypePolicy{})
+	if err != nil {
+		t.Fatalf("NewFileService: %v", err)
+	}
+	for _, key := range stale {
+		if _, err := blobStorage.Stat(key); err != storage.ErrNotFound {
+			t.Fatalf("Stat(%s): err = %v, want ErrNotFound", key, err)
+		}
+	}
+	checkUsage(s)
+
+	if err := s.DeleteFile(file.ID); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	blobs, err := blobStorage.List("")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for _, blob := range blobs {
+		t.Errorf("blob %s left after deleting the file", blob.Key)
+	}
+}
@@ -0,0 +1,265 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cloud-clipboard/internal/logger"
+)
+
+// UploadSession 断点续传上传会话
+type UploadSession struct {
+	ID        string `json:"id"`
+	Owner     string `json:"owner,omitempty"`
+	Length    int64  `json:"length"`
+	Offset    int64  `json:"-"`
+	Filename  string `json:"filename"`
+	Mimetype  string `json:"mimetype"`
+	Digest    string `json:"digest,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+	SharePolicy
+}
+
+// UploadManager 断点续传上传会话管理，会话信息和已上传的分片保存在dir目录下，
+// 已上传数据的长度即为当前偏移量，服务重启后可以继续上传
+type UploadManager struct {
+	dir   string
+	locks [uploadLockStripes]sync.Mutex
+}
+
+// uploadLockStripes 会话锁分片数量，按会话ID哈希选择，避免为每个会话单独维护锁
+const uploadLockStripes = 64
+
+// NewUploadManager 创建上传会话管理
+func NewUploadManager(dir string) (*UploadManager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload session directory: %w", err)
+	}
+
+	return &UploadManager{dir: dir}, nil
+}
+
+// Create 为命名空间owner创建上传会话，digest为客户端期望的SHA-256摘要，可以为空，policy在上传完成后应用到文件
+func (m *UploadManager) Create(owner string, length int64, filename, mimetype, digest string, policy SharePolicy) (*UploadSession, error) {
+	session := &UploadSession{
+		ID:          uuid.New().String(),
+		Owner:       owner,
+		Length:      length,
+		Filename:    filename,
+		Mimetype:    mimetype,
+		Digest:      digest,
+		CreatedAt:   time.Now().UnixMilli(),
+		SharePolicy: policy,
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal upload session: %w", err)
+	}
+
+	// 先创建数据文件，再写入会话信息，会话信息存在即表示会话可用
+	f, err := os.OpenFile(m.dataPath(session.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload data file: %w", err)
+	}
+	f.Close()
+
+	if err := writeFileAtomic(m.infoPath(session.ID), data); err != nil {
+		os.Remove(m.dataPath(session.ID))
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Get 获取上传会话，不存在时返回ErrUploadNotFound
+func (m *UploadManager) Get(id string) (*UploadSession, error) {
+	lock := m.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return m.load(id)
+}
+
+// Append 从offset处追加数据，offset必须等于当前偏移量，返回追加后的会话
+func (m *UploadManager) Append(id string, offset int64, r io.Reader) (*UploadSession, error) {
+	lock := m.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := m.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != session.Offset {
+		return session, ErrUploadOffsetMismatch
+	}
+
+	f, err := os.OpenFile(m.dataPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload data file: %w", err)
+	}
+	defer f.Close()
+
+	// 最多只接收剩余长度的数据，连接中断时已写入的部分仍然有效
+	written, copyErr := io.Copy(f, io.LimitReader(r, session.Length-session.Offset))
+	session.Offset += written
+
+	if err := f.Sync(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if copyErr != nil {
+		return session, fmt.Errorf("failed to write upload data: %w", copyErr)
+	}
+
+	return session, nil
+}
+
+// Complete 上传完成后在会话锁内读取全部数据并交给fn处理，fn成功后删除会话，
+// 保证同一会话只会被处理一次
+func (m *UploadManager) Complete(id string, fn func(session *UploadSession, r io.Reader) error) error {
+	lock := m.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	session, err := m.load(id)
+	if err != nil {
+		return err
+	}
+	if session.Offset != session.Length {
+		return ErrUploadIncomplete
+	}
+
+	f, err := os.Open(m.dataPath(id))
+	if err != nil {
+		return fmt.Errorf("failed to open upload data file: %w", err)
+	}
+	err = fn(session, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	return m.remove(id)
+}
+
+// Remove 删除上传会话及其数据
+func (m *UploadManager) Remove(id string) error {
+	lock := m.lock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := m.load(id); err != nil {
+		return err
+	}
+
+	return m.remove(id)
+}
+
+// CleanupStale 清理创建时间超过maxAge（毫秒）仍未完成的上传会话
+func (m *UploadManager) CleanupStale(maxAge int64) (int, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read upload session directory: %w", err)
+	}
+
+	now := time.Now().UnixMilli()
+	var removedCount int
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".info" {
+			continue
+		}
+
+		id := entry.Name()[:len(entry.Name())-len(".info")]
+		lock := m.lock(id)
+		lock.Lock()
+		session, err := m.load(id)
+		if err == nil && now-session.CreatedAt > maxAge {
+			if err := m.remove(id); err != nil {
+				logger.Errorf("Failed to remove stale upload session %s: %v", id, err)
+			} else {
+				removedCount++
+			}
+		}
+		lock.Unlock()
+	}
+
+	return removedCount, nil
+}
+
+// load 在已持有会话锁的情况下读取会话信息和当前偏移量
+func (m *UploadManager) load(id string) (*UploadSession, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(m.infoPath(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to read upload session: %w", err)
+	}
+
+	var session UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upload session: %w", err)
+	}
+
+	info, err := os.Stat(m.dataPath(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to stat upload data file: %w", err)
+	}
+	session.Offset = info.Size()
+
+	return &session, nil
+}
+
+// remove 在已持有会话锁的情况下删除会话文件
+func (m *UploadManager) remove(id string) error {
+	if err := os.Remove(m.infoPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove upload session: %w", err)
+	}
+	if err := os.Remove(m.dataPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove upload data file: %w", err)
+	}
+
+	return nil
+}
+
+// lock 获取会话锁
+func (m *UploadManager) lock(id string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return &m.locks[h.Sum32()%uploadLockStripes]
+}
+
+// infoPath 会话信息文件路径
+func (m *UploadManager) infoPath(id string) string {
+	return filepath.Join(m.dir, id+".info")
+}
+
+// dataPath 已上传数据文件路径
+func (m *UploadManager) dataPath(id string) string {
+	return filepath.Join(m.dir, id+".bin")
+}
+
+// 错误定义
+var (
+	ErrUploadNotFound       = errors.New("upload session not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	ErrUploadIncomplete     = errors.New("upload is incomplete")
+)
@@ -0,0 +1,180 @@
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// 预览类型
+const (
+	KindImage    = "image"
+	KindMarkdown = "markdown"
+	KindLog      = "log"
+	KindCode     = "code"
+	KindText     = "text"
+)
+
+// highlightStyle 代码高亮使用的配色
+const highlightStyle = "github"
+
+// imageMimetypes 可以直接内联展示的图片类型，不包含可能携带脚本的SVG
+var imageMimetypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// textMimetypes text/*以外按文本预览的类型
+var textMimetypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-sh":       true,
+	"application/x-yaml":     true,
+	"application/toml":       true,
+	"application/sql":        true,
+}
+
+// markdownExtensions Markdown文件扩展名
+var markdownExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+}
+
+// Kind 根据MIME类型和文件名判断预览类型，不支持预览时返回空字符串
+func Kind(mimetype, filename string) string {
+	mimetype = strings.ToLower(strings.TrimSpace(strings.Split(mimetype, ";")[0]))
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	switch {
+	case imageMimetypes[mimetype]:
+		return KindImage
+	case mimetype == "text/markdown" || markdownExtensions[ext]:
+		return KindMarkdown
+	case ext == ".log" || mimetype == "text/x-log":
+		return KindLog
+	case lexers.Match(filename) != nil:
+		return KindCode
+	case strings.HasPrefix(mimetype, "text/") || textMimetypes[mimetype]:
+		return KindText
+	}
+
+	return ""
+}
+
+// Render 读取最多maxBytes字节的文本内容并渲染为完整的HTML页面，超出部分截断；
+// 代码和文本按文件名或内容选择语法高亮，Markdown渲染后经过白名单过滤，日志只做转义
+func Render(r io.Reader, kind, filename string, maxBytes int64) ([]byte, error) {
+	data, truncated, err := readText(r, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	switch kind {
+	case KindMarkdown:
+		if err := renderMarkdown(&body, data); err != nil {
+			return nil, err
+		}
+	case KindCode, KindText:
+		if err := renderCode(&body, data, filename); err != nil {
+			return nil, err
+		}
+	case KindLog:
+		body.WriteString("<pre>")
+		body.WriteString(html.EscapeString(string(data)))
+		body.WriteString("</pre>")
+	default:
+		return nil, ErrUnsupported
+	}
+
+	var page bytes.Buffer
+	fmt.Fprintf(&page, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title>"+
+		"<style>body{margin:16px;font-family:sans-serif}pre{white-space:pre-wrap;word-break:break-all}</style>"+
+		"</head><body>", html.EscapeString(filename))
+	page.Write(body.Bytes())
+	if truncated {
+		fmt.Fprintf(&page, "<p><em>仅显示前%dKB内容</em></p>", maxBytes/1024)
+	}
+	page.WriteString("</body></html>\n")
+
+	return page.Bytes(), nil
+}
+
+// readText 读取最多maxBytes字节，截断时去掉末尾不完整的UTF-8字符，包含NUL字节时视为二进制文件
+func readText(r io.Reader, maxBytes int64) ([]byte, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	truncated := int64(len(data)) > maxBytes
+	if truncated {
+		data = data[:maxBytes]
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, false, ErrUnsupported
+	}
+
+	return bytes.ToValidUTF8(data, []byte("�")), truncated, nil
+}
+
+// renderMarkdown 渲染Markdown并过滤不安全的HTML
+func renderMarkdown(w io.Writer, data []byte) error {
+	var buf bytes.Buffer
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	if err := md.Convert(data, &buf); err != nil {
+		return fmt.Errorf("failed to render markdown: %w", err)
+	}
+
+	_, err := w.Write(bluemonday.UGCPolicy().SanitizeBytes(buf.Bytes()))
+	return err
+}
+
+// renderCode 语法高亮代码，使用内联样式以便在禁止外部资源的页面中显示
+func renderCode(w io.Writer, data []byte, filename string) error {
+	lexer := lexers.Match(filename)
+	if lexer == nil {
+		lexer = lexers.Analyse(string(data))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to tokenise code: %w", err)
+	}
+
+	formatter := chromahtml.New(chromahtml.WithClasses(false), chromahtml.TabWidth(4))
+	if err := formatter.Format(w, styles.Get(highlightStyle), iterator); err != nil {
+		return fmt.Errorf("failed to highlight code: %w", err)
+	}
+
+	return nil
+}
+
+// 错误定义
+var (
+	ErrUnsupported = errors.New("preview not supported for this file type")
+)
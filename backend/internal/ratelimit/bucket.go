@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// meterWindow 吞吐量统计窗口（秒）
+const meterWindow = 5
+
+// Bucket 令牌桶，令牌单位为字节，rate小于等于0表示不限速
+type Bucket struct {
+	rate     float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+	lastUsed time.Time
+	total    int64
+	slots    [meterWindow]int64
+	slotSecs [meterWindow]int64
+	mu       sync.Mutex
+}
+
+// BucketStats 令牌桶统计信息
+type BucketStats struct {
+	Key        string  `json:"key"`
+	Rate       int64   `json:"rate"`
+	Burst      int64   `json:"burst"`
+	Throughput float64 `json:"throughput"`
+	TotalBytes int64   `json:"totalBytes"`
+	LastUsed   int64   `json:"lastUsed"`
+}
+
+// NewBucket 创建令牌桶，burst小于等于0时取rate，初始令牌为满
+func NewBucket(rate, burst int64) *Bucket {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	now := time.Now()
+	return &Bucket{
+		rate:     float64(rate),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     now,
+		lastUsed: now,
+	}
+}
+
+// Unlimited 是否不限速
+func (b *Bucket) Unlimited() bool {
+	return b.rate <= 0
+}
+
+// Burst 单次最多可获取的令牌数，不限速时返回0
+func (b *Bucket) Burst() int {
+	if b.Unlimited() {
+		return 0
+	}
+	return int(b.burst)
+}
+
+// reserve 预留n个令牌并记录吞吐量，返回需要等待的时间，令牌不足时允许欠账
+func (b *Bucket) reserve(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastUsed = now
+	b.record(n, now)
+
+	if b.Unlimited() {
+		return 0
+	}
+
+	b.refill(now)
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel 归还未使用的令牌，用于等待被取消的情况
+func (b *Bucket) cancel(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.total -= int64(n)
+	if b.Unlimited() {
+		return
+	}
+
+	b.tokens += float64(n)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// refill 按经过的时间补充令牌
+func (b *Bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// record 记录吞吐量，按秒分槽统计最近meterWindow秒的数据
+func (b *Bucket) record(n int, now time.Time) {
+	sec := now.Unix()
+	slot := sec % meterWindow
+	if b.slotSecs[slot] != sec {
+		b.slotSecs[slot] = sec
+		b.slots[slot] = 0
+	}
+	b.slots[slot] += int64(n)
+	b.total += int64(n)
+}
+
+// idleSince 最后一次使用的时间
+func (b *Bucket) idleSince() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.lastUsed
+}
+
+// Stats 获取统计信息，吞吐量为最近meterWindow秒的平均字节每秒
+func (b *Bucket) Stats(key string) BucketStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().Unix()
+	var recent int64
+	for i, sec := range b.slotSecs {
+		if now-sec < meterWindow {
+			recent += b.slots[i]
+		}
+	}
+
+	return BucketStats{
+		Key:        key,
+		Rate:       int64(b.rate),
+		Burst:      int64(b.burst),
+		Throughput: float64(recent) / meterWindow,
+		TotalBytes: b.total,
+		LastUsed:   b.lastUsed.UnixMilli(),
+	}
+}
@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultChunkSize 不限速时单次读写的最大字节数
+const defaultChunkSize = 32 * 1024
+
+// Config 限速配置，速率单位为字节每秒，小于等于0表示不限速
+type Config struct {
+	GlobalRate  int64
+	GlobalBurst int64
+	ClientRate  int64
+	ClientBurst int64
+}
+
+// Limiter 带宽限速器，所有传输共享全局令牌桶，每个客户端（IP或令牌）另有独立的令牌桶，
+// 传输数据时需要同时从两个桶中获取令牌
+type Limiter struct {
+	config  Config
+	global  *Bucket
+	clients map[string]*Bucket
+	chunk   int
+	mu      sync.Mutex
+}
+
+// Stats 限速器统计信息
+type Stats struct {
+	Global  BucketStats   `json:"global"`
+	Clients []BucketStats `json:"clients"`
+}
+
+// New 创建带宽限速器
+func New(config Config) *Limiter {
+	l := &Limiter{
+		config:  config,
+		global:  NewBucket(config.GlobalRate, config.GlobalBurst),
+		clients: make(map[string]*Bucket),
+	}
+
+	// 单次读写的最大字节数不超过任一令牌桶的容量
+	l.chunk = defaultChunkSize
+	for _, burst := range []int{l.global.Burst(), NewBucket(config.ClientRate, config.ClientBurst).Burst()} {
+		if burst > 0 && burst < l.chunk {
+			l.chunk = burst
+		}
+	}
+
+	return l
+}
+
+// Wait 为客户端获取n个字节的令牌，令牌不足时阻塞直到可用或ctx结束
+func (l *Limiter) Wait(ctx context.Context, client string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	clientBucket := l.client(client)
+	now := time.Now()
+	delay := l.global.reserve(n, now)
+	if d := clientBucket.reserve(n, now); d > delay {
+		delay = d
+	}
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.global.cancel(n)
+		clientBucket.cancel(n)
+		return ctx.Err()
+	}
+}
+
+// Writer 返回对客户端限速的Writer
+func (l *Limiter) Writer(ctx context.Context, w io.Writer, client string) io.Writer {
+	return &limitedWriter{ctx: ctx, w: w, limiter: l, client: client}
+}
+
+// Reader 返回对客户端限速的Reader
+func (l *Limiter) Reader(ctx context.Context, r io.Reader, client string) io.Reader {
+	return &limitedReader{ctx: ctx, r: r, limiter: l, client: client}
+}
+
+// Stats 获取全局和各客户端的统计信息，客户端按吞吐量从高到低排序
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	clients := make([]BucketStats, 0, len(l.clients))
+	for key, bucket := range l.clients {
+		clients = append(clients, bucket.Stats(key))
+	}
+	l.mu.Unlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].Throughput != clients[j].Throughput {
+			return clients[i].Throughput > clients[j].Throughput
+		}
+		return clients[i].Key < clients[j].Key
+	})
+
+	return Stats{
+		Global:  l.global.Stats("global"),
+		Clients: clients,
+	}
+}
+
+// Prune 删除空闲超过idle的客户端令牌桶，返回删除的数量
+func (l *Limiter) Prune(idle time.Duration) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	deadline := time.Now().Add(-idle)
+	var removedCount int
+	for key, bucket := range l.clients {
+		if bucket.idleSince().Before(deadline) {
+			delete(l.clients, key)
+			removedCount++
+		}
+	}
+
+	return removedCount
+}
+
+// client 获取客户端令牌桶，不存在时创建
+func (l *Limiter) client(key string) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.clients[key]
+	if !ok {
+		bucket = NewBucket(l.config.ClientRate, l.config.ClientBurst)
+		l.clients[key] = bucket
+	}
+
+	return bucket
+}
+
+// limitedWriter 限速Writer，大块数据拆分后逐块获取令牌
+type limitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *Limiter
+	client  string
+}
+
+// Write 写入数据
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	chunkSize := w.limiter.chunk
+
+	var written int
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+
+		if err := w.limiter.Wait(w.ctx, w.client, len(chunk)); err != nil {
+			return written, err
+		}
+
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// limitedReader 限速Reader，读取后按实际读取的字节数获取令牌
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+	client  string
+}
+
+// Read 读取数据
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.chunk {
+		p = p[:r.limiter.chunk]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.Wait(r.ctx, r.client, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
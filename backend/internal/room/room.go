@@ -0,0 +1,359 @@
+package room
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NamespacePrefix 房间命名空间的前缀，包含令牌命名空间不允许使用的冒号，不会与令牌的命名空间冲突
+const NamespacePrefix = "room:"
+
+// codeSpace 8位数字配对码的取值范围
+const codeSpace = 100_000_000
+
+// memberPrefix 成员令牌前缀
+const memberPrefix = "ccr_"
+
+// attemptWindow 加入失败次数的统计窗口
+const attemptWindow = time.Minute
+
+// Room 房间信息，房间内的设备共用同一个剪切板和文件空间
+type Room struct {
+	ID        string `json:"id"`
+	Code      string `json:"code"`
+	CreatedAt int64  `json:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+	Members   int    `json:"members"`
+}
+
+// Namespace 房间的剪切板和文件所在的命名空间
+func (r *Room) Namespace() string {
+	return NamespacePrefix + r.ID
+}
+
+// IsNamespace 命名空间是否属于房间
+func IsNamespace(namespace string) bool {
+	return strings.HasPrefix(namespace, NamespacePrefix)
+}
+
+// Options 房间配置，时间单位为毫秒
+type Options struct {
+	// TTL 默认有效期
+	TTL int64
+	// MaxTTL 创建者可设置的最长有效期
+	MaxTTL int64
+	// MaxRooms 同时存在的最大房间数
+	MaxRooms int
+	// MaxMembers 单个房间的最大设备数
+	MaxMembers int
+	// JoinAttempts 单个客户端每分钟允许的加入失败次数，防止穷举配对码
+	JoinAttempts int
+	// CodeAttempts 每个配对码在有效期间允许的加入失败次数（所有客户端合计，不依赖客户端标识），
+	// 用完后更换房间的配对码，更换IP也无法持续穷举同一个配对码；不会拒绝其他房间或正确配对码的加入
+	CodeAttempts int
+}
+
+// room 房间及其成员，成员只保存令牌的SHA-256摘要；failures为当前配对码生成后所有客户端的加入失败次数
+type room struct {
+	Room
+	members  map[string]struct{}
+	failures int
+}
+
+// joinAttempts 客户端的加入失败记录
+type joinAttempts struct {
+	count   int
+	resetAt time.Time
+}
+
+// Manager 房间管理，房间只保存在内存中，服务重启后所有房间失效；
+// 房间过期或最后一个设备离开时关闭，关闭后调用onClose清理房间的剪切板和文件
+type Manager struct {
+	opts     Options
+	rooms    map[string]*room
+	codes    map[string]*room
+	members  map[string]*room
+	attempts map[string]*joinAttempts
+	onClose  func(*Room)
+	mu       sync.Mutex
+}
+
+// NewManager 创建房间管理，onClose在房间关闭后调用（不持有锁），可以为nil
+func NewManager(opts Options, onClose func(*Room)) *Manager {
+	return &Manager{
+		opts:     opts,
+		rooms:    make(map[string]*room),
+		codes:    make(map[string]*room),
+		members:  make(map[string]*room),
+		attempts: make(map[string]*joinAttempts),
+		onClose:  onClose,
+	}
+}
+
+// Create 创建房间，ttl为有效期（毫秒），0表示使用默认有效期；创建者自动成为第一个成员，返回其成员令牌
+func (m *Manager) Create(ttl int64) (*Room, string, error) {
+	if ttl == 0 {
+		ttl = m.opts.TTL
+	}
+	if ttl < 0 || (m.opts.MaxTTL > 0 && ttl > m.opts.MaxTTL) {
+		return nil, "", ErrInvalidTTL
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.opts.MaxRooms > 0 && len(m.rooms) >= m.opts.MaxRooms {
+		return nil, "", ErrTooManyRooms
+	}
+
+	code, err := m.generateCode()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UnixMilli()
+	r := &room{
+		Room: Room{
+			ID:        strings.ReplaceAll(uuid.New().String(), "-", ""),
+			Code:      code,
+			CreatedAt: now,
+			ExpiresAt: now + ttl,
+		},
+		members: make(map[string]struct{}),
+	}
+	m.rooms[r.ID] = r
+	m.codes[r.Code] = r
+
+	secret, err := m.addMember(r)
+	if err != nil {
+		m.removeRoom(r)
+		return nil, "", err
+	}
+
+	return r.snapshot(), secret, nil
+}
+
+// Join 使用配对码加入房间，返回成员令牌；client用于统计失败次数，该客户端的失败次数过多时返回ErrTooManyAttempts，
+// 配对码无效或房间已过期时返回ErrRoomNotFound。失败的尝试可能针对任何房间，因此计入所有房间当前配对码的失败次数
+func (m *Manager) Join(code, client string) (*Room, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	attempts := m.attempts[client]
+	if attempts != nil && now.After(attempts.resetAt) {
+		delete(m.attempts, client)
+		attempts = nil
+	}
+	if attempts != nil && m.opts.JoinAttempts > 0 && attempts.count >= m.opts.JoinAttempts {
+		return nil, "", ErrTooManyAttempts
+	}
+
+	r, ok := m.codes[code]
+	if !ok || r.expired(now.UnixMilli()) {
+		if attempts == nil {
+			attempts = &joinAttempts{resetAt: now.Add(attemptWindow)}
+			m.attempts[client] = attempts
+		}
+		attempts.count++
+		m.countFailure()
+		return nil, "", ErrRoomNotFound
+	}
+
+	if m.opts.MaxMembers > 0 && len(r.members) >= m.opts.MaxMembers {
+		return nil, "", ErrRoomFull
+	}
+
+	secret, err := m.addMember(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return r.snapshot(), secret, nil
+}
+
+// Authenticate 根据成员令牌获取所在的房间，令牌无效或房间已过期时返回ErrInvalidMember
+func (m *Manager) Authenticate(secret string) (*Room, error) {
+	if !strings.HasPrefix(secret, memberPrefix) {
+		return nil, ErrInvalidMember
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.members[hashSecret(secret)]
+	if !ok || r.expired(time.Now().UnixMilli()) {
+		return nil, ErrInvalidMember
+	}
+
+	return r.snapshot(), nil
+}
+
+// Leave 离开房间，最后一个成员离开时关闭房间，返回离开后的房间信息以及房间是否已关闭
+func (m *Manager) Leave(secret string) (*Room, bool, error) {
+	hash := hashSecret(secret)
+
+	m.mu.Lock()
+	r, ok := m.members[hash]
+	if !ok {
+		m.mu.Unlock()
+		return nil, false, ErrInvalidMember
+	}
+
+	delete(m.members, hash)
+	delete(r.members, hash)
+	closed := len(r.members) == 0
+	if closed {
+		m.removeRoom(r)
+	}
+	snapshot := r.snapshot()
+	m.mu.Unlock()
+
+	if closed && m.onClose != nil {
+		m.onClose(snapshot)
+	}
+
+	return snapshot, closed, nil
+}
+
+// RemoveExpired 关闭所有已过期的房间并清理过期的失败记录，返回关闭的房间数量
+func (m *Manager) RemoveExpired() int {
+	m.mu.Lock()
+	now := time.Now()
+	var expired []*Room
+	for _, r := range m.rooms {
+		if r.expired(now.UnixMilli()) {
+			m.removeRoom(r)
+			expired = append(expired, r.snapshot())
+		}
+	}
+	for client, attempts := range m.attempts {
+		if now.After(attempts.resetAt) {
+			delete(m.attempts, client)
+		}
+	}
+	m.mu.Unlock()
+
+	if m.onClose != nil {
+		for _, r := range expired {
+			m.onClose(r)
+		}
+	}
+
+	return len(expired)
+}
+
+// Count 获取当前房间数量
+func (m *Manager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.rooms)
+}
+
+// addMember 为房间添加成员并返回成员令牌，调用方需持有锁
+func (m *Manager) addMember(r *room) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate member token: %w", err)
+	}
+	secret := memberPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	hash := hashSecret(secret)
+	r.members[hash] = struct{}{}
+	m.members[hash] = r
+
+	return secret, nil
+}
+
+// countFailure 将一次加入失败计入所有房间当前的配对码，失败次数用完的配对码被更换，调用方需持有锁
+func (m *Manager) countFailure() {
+	if m.opts.CodeAttempts <= 0 {
+		return
+	}
+
+	for _, r := range m.rooms {
+		r.failures++
+		if r.failures < m.opts.CodeAttempts {
+			continue
+		}
+
+		code, err := m.generateCode()
+		if err != nil {
+			// 无法生成新的配对码时停止通过配对码加入，已有成员不受影响
+			code = ""
+		}
+		delete(m.codes, r.Code)
+		r.Code = code
+		r.failures = 0
+		if code != "" {
+			m.codes[code] = r
+		}
+	}
+}
+
+// removeRoom 移除房间及其所有成员，调用方需持有锁
+func (m *Manager) removeRoom(r *room) {
+	for hash := range r.members {
+		delete(m.members, hash)
+	}
+	r.members = make(map[string]struct{})
+	delete(m.codes, r.Code)
+	delete(m.rooms, r.ID)
+}
+
+// generateCode 生成未被使用的配对码，调用方需持有锁
+func (m *Manager) generateCode() (string, error) {
+	limit := big.NewInt(codeSpace)
+	for i := 0; i < 100; i++ {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate room code: %w", err)
+		}
+
+		code := fmt.Sprintf("%08d", n.Int64())
+		if _, ok := m.codes[code]; !ok {
+			return code, nil
+		}
+	}
+
+	return "", ErrTooManyRooms
+}
+
+// expired 房间是否已过期
+func (r *room) expired(now int64) bool {
+	return r.ExpiresAt <= now
+}
+
+// snapshot 房间信息的副本
+func (r *room) snapshot() *Room {
+	snapshot := r.Room
+	snapshot.Members = len(r.members)
+	return &snapshot
+}
+
+// hashSecret 计算成员令牌的摘要
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// 错误定义
+var (
+	ErrRoomNotFound    = errors.New("room not found")
+	ErrInvalidMember   = errors.New("invalid or expired room token")
+	ErrRoomFull        = errors.New("room is full")
+	ErrTooManyRooms    = errors.New("too many rooms")
+	ErrTooManyAttempts = errors.New("too many failed join attempts")
+	ErrInvalidTTL      = errors.New("invalid room ttl")
+)
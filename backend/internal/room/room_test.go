@@ -0,0 +1,106 @@
+package room
+
+import (
+	"fmt"
+	"testing"
+)
+
+// wrongCode 返回与房间配对码不同的配对码
+func wrongCode(r *Room) string {
+	if r.Code == "00000000" {
+		return "00000001"
+	}
+	return "00000000"
+}
+
+func TestJoinAttempts(t *testing.T) {
+	tests := []struct {
+		name          string
+		joinAttempts  int
+		clients       int
+		wantBlockedAt int
+	}{
+		{"per client limit", 3, 1, 3},
+		{"other clients are not limited", 3, 10, -1},
+		{"no limits", 0, 1, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(Options{TTL: 60000, JoinAttempts: tt.joinAttempts}, nil)
+			r, _, err := m.Create(0)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			for i := 0; i < 20; i++ {
+				client := fmt.Sprintf("10.0.0.%d", i%tt.clients)
+				_, _, err := m.Join(wrongCode(r), client)
+				if i == tt.wantBlockedAt {
+					if err != ErrTooManyAttempts {
+						t.Fatalf("attempt %d: err = %v, want ErrTooManyAttempts", i, err)
+					}
+					// 被限制的客户端使用正确的配对码同样被拒绝
+					if _, _, err := m.Join(r.Code, client); err != ErrTooManyAttempts {
+						t.Fatalf("join with valid code: err = %v, want ErrTooManyAttempts", err)
+					}
+					break
+				}
+				if err != ErrRoomNotFound {
+					t.Fatalf("attempt %d: err = %v, want ErrRoomNotFound", i, err)
+				}
+			}
+
+			// 其他客户端用完失败次数后，正确的配对码仍然可以加入
+			if _, _, err := m.Join(r.Code, "10.0.1.1"); err != nil {
+				t.Fatalf("join with valid code: %v", err)
+			}
+		})
+	}
+}
+
+func TestCodeAttemptsRotateCode(t *testing.T) {
+	const codeAttempts = 5
+
+	m := NewManager(Options{TTL: 60000, JoinAttempts: 2, CodeAttempts: codeAttempts}, nil)
+	r, secret, err := m.Create(0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// 每个客户端都在自己的限制之内，合计的失败次数用完配对码的次数
+	for i := 0; i < codeAttempts-1; i++ {
+		if _, _, err := m.Join(wrongCode(r), fmt.Sprintf("10.0.0.%d", i)); err != ErrRoomNotFound {
+			t.Fatalf("attempt %d: err = %v, want ErrRoomNotFound", i, err)
+		}
+	}
+	current, err := m.Authenticate(secret)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if current.Code != r.Code {
+		t.Fatalf("code changed after %d failures", codeAttempts-1)
+	}
+	if _, _, err := m.Join(wrongCode(r), "10.0.1.0"); err != ErrRoomNotFound {
+		t.Fatalf("last attempt: err = %v, want ErrRoomNotFound", err)
+	}
+
+	// 配对码已更换，旧的配对码失效，房间成员可以获取新的配对码
+	current, err = m.Authenticate(secret)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if current.Code == r.Code || len(current.Code) != 8 {
+		t.Fatalf("code = %q after %d failures, want a new 8 digit code", current.Code, codeAttempts)
+	}
+	if _, _, err := m.Join(r.Code, "10.0.2.0"); err != ErrRoomNotFound {
+		t.Fatalf("join with old code: err = %v, want ErrRoomNotFound", err)
+	}
+	joined, _, err := m.Join(current.Code, "10.0.2.1")
+	if err != nil {
+		t.Fatalf("join with new code: %v", err)
+	}
+	if joined.ID != r.ID || joined.Members != 2 {
+		t.Fatalf("joined room %s with %d members, want %s with 2", joined.ID, joined.Members, r.ID)
+	}
+}
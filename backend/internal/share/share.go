@@ -0,0 +1,168 @@
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 分享内容的类型
+const (
+	KindFile = "file"
+	KindText = "text"
+)
+
+// keySize 签名密钥的字节数
+const keySize = 32
+
+// Link 分享链接，链接本身携带ID、过期时间和允许使用次数并由HMAC签名，
+// 服务端保存使用次数和访问密码，删除记录即吊销链接
+type Link struct {
+	ID           string `json:"id"`
+	Kind         string `json:"kind"`
+	Target       string `json:"target"`
+	Namespace    string `json:"namespace,omitempty"`
+	CreatedBy    string `json:"createdBy,omitempty"`
+	CreatedAt    int64  `json:"createdAt"`
+	ExpiresAt    int64  `json:"expiresAt"`
+	MaxUses      int    `json:"maxUses"`
+	Uses         int    `json:"uses"`
+	PasswordHash string `json:"passwordHash,omitempty"`
+}
+
+// Policy 创建分享链接时指定的策略
+type Policy struct {
+	// ExpiresIn 有效期（毫秒），必须为正数
+	ExpiresIn int64
+	// MaxUses 允许使用次数，0表示不限制
+	MaxUses int
+	// PasswordHash 访问密码的bcrypt哈希，为空表示不需要密码
+	PasswordHash string
+}
+
+// Protected 链接是否设置了访问密码
+func (l *Link) Protected() bool {
+	return l.PasswordHash != ""
+}
+
+// CheckPassword 校验访问密码，未设置密码时总是通过
+func (l *Link) CheckPassword(password string) bool {
+	if !l.Protected() {
+		return true
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(l.PasswordHash), []byte(password)) == nil
+}
+
+// Expired 链接在now时是否已过期
+func (l *Link) Expired(now int64) bool {
+	return now >= l.ExpiresAt
+}
+
+// usesExhausted 使用次数是否已用完
+func (l *Link) usesExhausted() bool {
+	return l.MaxUses > 0 && l.Uses >= l.MaxUses
+}
+
+// Signer 分享链接的HMAC-SHA256签名
+type Signer struct {
+	key []byte
+}
+
+// NewSigner 创建签名器，secret不为空时直接作为密钥（多个实例共用同一密钥），
+// 否则从keyFile读取，文件不存在时生成随机密钥并保存；删除密钥文件会使所有已分享的链接失效
+func NewSigner(secret, keyFile string) (*Signer, error) {
+	if secret != "" {
+		return &Signer{key: []byte(secret)}, nil
+	}
+
+	key, err := os.ReadFile(keyFile)
+	if err == nil {
+		if len(key) < keySize {
+			return nil, fmt.Errorf("share key %s is too short", keyFile)
+		}
+		return &Signer{key: key}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read share key: %w", err)
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate share key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create share key directory: %w", err)
+	}
+	// 密钥文件只允许服务进程读取
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write share key: %w", err)
+	}
+
+	return &Signer{key: key}, nil
+}
+
+// Sign 生成链接令牌，格式为<ID>.<过期时间>.<允许使用次数>.<签名>
+func (s *Signer) Sign(link *Link) string {
+	payload := fmt.Sprintf("%s.%d.%d", link.ID, link.ExpiresAt, link.MaxUses)
+	return payload + "." + s.signature(payload)
+}
+
+// claims 链接令牌中携带的信息
+type claims struct {
+	id        string
+	expiresAt int64
+	maxUses   int
+}
+
+// verify 校验令牌签名并解析其中的信息，格式错误或签名不一致时返回ErrInvalidLink
+func (s *Signer) verify(token string) (*claims, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return nil, ErrInvalidLink
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.signature(payload))) {
+		return nil, ErrInvalidLink
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidLink
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidLink
+	}
+	maxUses, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, ErrInvalidLink
+	}
+
+	return &claims{id: parts[0], expiresAt: expiresAt, maxUses: maxUses}, nil
+}
+
+// signature 计算payload的签名
+func (s *Signer) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// 错误定义
+var (
+	ErrInvalidLink   = errors.New("invalid share link")
+	ErrLinkNotFound  = errors.New("share link not found")
+	ErrLinkExpired   = errors.New("share link expired")
+	ErrUsesExhausted = errors.New("share link uses exhausted")
+	ErrInvalidPolicy = errors.New("invalid share policy")
+)
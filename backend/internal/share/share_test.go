@@ -0,0 +1,184 @@
+package share
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStore 创建使用临时目录的分享链接存储
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := NewStore(filepath.Join(t.TempDir(), "shares.json"), &Signer{key: []byte("test-key")})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	return s
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := &Signer{key: []byte("test-key")}
+	link := &Link{ID: "abc", ExpiresAt: 1700000000000, MaxUses: 3}
+	token := signer.Sign(link)
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", token, true},
+		{"empty", "", false},
+		{"no signature", "abc.1700000000000.3", false},
+		{"extended expiry", strings.Join([]string{parts[0], "1800000000000", parts[2], parts[3]}, "."), false},
+		{"more uses", strings.Join([]string{parts[0], parts[1], "0", parts[3]}, "."), false},
+		{"other id", strings.Join([]string{"abd", parts[1], parts[2], parts[3]}, "."), false},
+		{"truncated signature", token[:len(token)-1], false},
+		{"other key", (&Signer{key: []byte("other-key")}).Sign(link), false},
+		{"extra field", "x." + token, false},
+		{"signature only", "." + parts[3], false},
+	}
+
+	for _, tt := range tests {
+		claims, err := signer.verify(tt.token)
+		if !tt.valid {
+			if err != ErrInvalidLink {
+				t.Errorf("%s: verify err = %v, want ErrInvalidLink", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: verify: %v", tt.name, err)
+			continue
+		}
+		if claims.id != link.ID || claims.expiresAt != link.ExpiresAt || claims.maxUses != link.MaxUses {
+			t.Errorf("%s: claims = %+v, want %s/%d/%d", tt.name, claims, link.ID, link.ExpiresAt, link.MaxUses)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	s := newTestStore(t)
+	link, token, err := s.Create(KindFile, "file", "ns", "creator", Policy{ExpiresIn: int64(time.Hour / time.Millisecond), MaxUses: 1})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if got, err := s.Resolve(token); err != nil || got.ID != link.ID {
+		t.Fatalf("Resolve = %v, %v", got, err)
+	}
+	if _, err := s.Use(link.ID, false); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	// 次数已用完的链接仍可解析，由Use拒绝新的使用
+	if _, err := s.Resolve(token); err != nil {
+		t.Fatalf("Resolve exhausted: %v", err)
+	}
+	if _, err := s.Use(link.ID, false); err != ErrUsesExhausted {
+		t.Fatalf("Use exhausted: err = %v, want ErrUsesExhausted", err)
+	}
+	if counted, err := s.Use(link.ID, true); err != nil || counted {
+		t.Fatalf("Use exhausted resume: counted = %v, err = %v", counted, err)
+	}
+
+	if err := s.Revoke("other", link.ID); err != ErrLinkNotFound {
+		t.Fatalf("Revoke from other namespace: err = %v, want ErrLinkNotFound", err)
+	}
+	if err := s.Revoke("ns", link.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := s.Resolve(token); err != ErrLinkNotFound {
+		t.Fatalf("Resolve revoked: err = %v, want ErrLinkNotFound", err)
+	}
+
+	expired := &Link{ID: "expired", ExpiresAt: time.Now().Add(-time.Minute).UnixMilli()}
+	if _, err := s.Resolve(s.Token(expired)); err != ErrLinkExpired {
+		t.Fatalf("Resolve expired: err = %v, want ErrLinkExpired", err)
+	}
+}
+
+func TestUse(t *testing.T) {
+	type step struct {
+		resume  bool
+		refund  bool
+		counted bool
+		want    error
+	}
+
+	tests := []struct {
+		name    string
+		maxUses int
+		steps   []step
+	}{
+		{
+			name:    "limited uses",
+			maxUses: 2,
+			steps: []step{
+				{counted: true},
+				{counted: true},
+				{want: ErrUsesExhausted},
+			},
+		},
+		{
+			name:    "resume before first use is counted",
+			maxUses: 1,
+			steps: []step{
+				{resume: true, counted: true},
+				{resume: true},
+				{want: ErrUsesExhausted},
+			},
+		},
+		{
+			name:    "refund restores a use",
+			maxUses: 1,
+			steps: []step{
+				{counted: true, refund: true},
+				{counted: true},
+				{want: ErrUsesExhausted},
+			},
+		},
+		{
+			name:    "unlimited uses",
+			maxUses: 0,
+			steps: []step{
+				{counted: true},
+				{counted: true},
+				{counted: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			link, _, err := s.Create(KindFile, "file", "ns", "", Policy{ExpiresIn: int64(time.Hour / time.Millisecond), MaxUses: tt.maxUses})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			for i, step := range tt.steps {
+				counted, err := s.Use(link.ID, step.resume)
+				if err != step.want || counted != step.counted {
+					t.Fatalf("step %d: Use = %v, %v, want %v, %v", i, counted, err, step.counted, step.want)
+				}
+				if step.refund {
+					if err := s.Refund(link.ID); err != nil {
+						t.Fatalf("step %d: Refund: %v", i, err)
+					}
+				}
+			}
+
+			// 使用次数在重启后仍然有效
+			reloaded, err := NewStore(s.path, s.signer)
+			if err != nil {
+				t.Fatalf("NewStore: %v", err)
+			}
+			if got, want := reloaded.links[link.ID].Uses, s.links[link.ID].Uses; got != want {
+				t.Fatalf("reloaded uses = %d, want %d", got, want)
+			}
+		})
+	}
+}
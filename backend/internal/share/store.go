@@ -0,0 +1,289 @@
+package share
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store 基于JSON文件的分享链接存储，使用次数和吊销在重启后仍然有效
+type Store struct {
+	path   string
+	signer *Signer
+	links  map[string]*Link
+	mu     sync.Mutex
+}
+
+// NewStore 创建分享链接存储，文件不存在时视为没有链接
+func NewStore(path string, signer *Signer) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create share directory: %w", err)
+	}
+
+	s := &Store{path: path, signer: signer, links: make(map[string]*Link)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Create 为namespace中的内容创建分享链接，返回链接记录和签名后的令牌
+func (s *Store) Create(kind, target, namespace, createdBy string, policy Policy) (*Link, string, error) {
+	if (kind != KindFile && kind != KindText) || target == "" || policy.ExpiresIn <= 0 || policy.MaxUses < 0 {
+		return nil, "", ErrInvalidPolicy
+	}
+
+	now := time.Now().UnixMilli()
+	link := &Link{
+		ID:           strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Kind:         kind,
+		Target:       target,
+		Namespace:    namespace,
+		CreatedBy:    createdBy,
+		CreatedAt:    now,
+		ExpiresAt:    now + policy.ExpiresIn,
+		MaxUses:      policy.MaxUses,
+		PasswordHash: policy.PasswordHash,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.links[link.ID] = link
+	if err := s.save(); err != nil {
+		delete(s.links, link.ID)
+		return nil, "", err
+	}
+
+	copied := *link
+	return &copied, s.signer.Sign(link), nil
+}
+
+// Resolve 校验令牌并获取链接，不计入使用次数；签名无效或与记录不一致时返回ErrInvalidLink，
+// 已吊销时返回ErrLinkNotFound，已过期时返回ErrLinkExpired；次数是否用完由Use检查，
+// 次数已用完的链接仍然可以续传服务端记录的中断下载
+func (s *Store) Resolve(token string) (*Link, error) {
+	claims, err := s.signer.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	// 过期时间由签名保证，不需要查询记录
+	now := time.Now().UnixMilli()
+	if now >= claims.expiresAt {
+		return nil, ErrLinkExpired
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[claims.id]
+	if !ok {
+		return nil, ErrLinkNotFound
+	}
+	if link.ExpiresAt != claims.expiresAt || link.MaxUses != claims.maxUses {
+		return nil, ErrInvalidLink
+	}
+
+	copied := *link
+	return &copied, nil
+}
+
+// Use 原子地检查并增加使用次数，返回本次是否计数；resume为true表示请求是服务端确认的中断下载的续传
+// （不能由客户端的请求头决定），链接已被使用过时不再计数，即使次数已用完也允许；次数已用完时返回ErrUsesExhausted
+func (s *Store) Use(id string, resume bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[id]
+	if !ok {
+		return false, ErrLinkNotFound
+	}
+	if link.Expired(time.Now().UnixMilli()) {
+		return false, ErrLinkExpired
+	}
+
+	if resume && link.Uses > 0 {
+		return false, nil
+	}
+	if link.usesExhausted() {
+		return false, ErrUsesExhausted
+	}
+	link.Uses++
+	if err := s.save(); err != nil {
+		link.Uses--
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Refund 退还一次使用次数，用于兑换后下载失败的情况；链接已被吊销时忽略
+func (s *Store) Refund(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[id]
+	if !ok || link.Uses == 0 {
+		return nil
+	}
+
+	link.Uses--
+	if err := s.save(); err != nil {
+		link.Uses++
+		return err
+	}
+
+	return nil
+}
+
+// Token 重新生成链接的令牌，令牌由链接记录确定，列出链接时不需要保存明文
+func (s *Store) Token(link *Link) string {
+	return s.signer.Sign(link)
+}
+
+// List 获取namespace中的所有链接（按创建时间排序）
+func (s *Store) List(namespace string) []*Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links := make([]*Link, 0)
+	for _, link := range s.links {
+		if link.Namespace == namespace {
+			copied := *link
+			links = append(links, &copied)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt < links[j].CreatedAt
+	})
+
+	return links
+}
+
+// Revoke 吊销namespace中的链接，其他命名空间的链接视为不存在
+func (s *Store) Revoke(namespace, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[id]
+	if !ok || link.Namespace != namespace {
+		return ErrLinkNotFound
+	}
+
+	delete(s.links, id)
+	if err := s.save(); err != nil {
+		s.links[id] = link
+		return err
+	}
+
+	return nil
+}
+
+// RemoveNamespace 删除所有满足match的命名空间中的链接（例如已关闭的房间），返回删除数量
+func (s *Store) RemoveNamespace(match func(namespace string) bool) (int, error) {
+	return s.remove(func(link *Link) bool {
+		return match(link.Namespace)
+	})
+}
+
+// RemoveExpired 删除所有已过期的链接，返回删除数量
+func (s *Store) RemoveExpired() (int, error) {
+	now := time.Now().UnixMilli()
+	return s.remove(func(link *Link) bool {
+		return link.Expired(now)
+	})
+}
+
+// remove 删除所有满足match的链接
+func (s *Store) remove(match func(*Link) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make(map[string]*Link)
+	for id, link := range s.links {
+		if match(link) {
+			removed[id] = link
+			delete(s.links, id)
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+
+	if err := s.save(); err != nil {
+		for id, link := range removed {
+			s.links[id] = link
+		}
+		return 0, err
+	}
+
+	return len(removed), nil
+}
+
+// load 读取链接文件
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read share file: %w", err)
+	}
+
+	var list []*Link
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse share file: %w", err)
+	}
+	for _, link := range list {
+		s.links[link.ID] = link
+	}
+
+	return nil
+}
+
+// save 原子地写入链接文件，调用方需持有锁
+func (s *Store) save() error {
+	list := make([]*Link, 0, len(s.links))
+	for _, link := range s.links {
+		list = append(list, link)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt < list[j].CreatedAt
+	})
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal share links: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write share file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close share file: %w", err)
+	}
+	// 链接文件包含访问密码的哈希，只允许服务进程读取
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("failed to chmod share file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to rename share file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,519 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload 不对请求体签名，避免为计算哈希而缓存整个文件
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3PartSize 长度未知时分片上传的分片大小（S3要求除最后一片外不小于5MB）
+const s3PartSize = 8 * 1024 * 1024
+
+var _ Storage = (*S3Storage)(nil)
+
+// S3Options S3兼容存储配置
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+	PathStyle bool
+}
+
+// S3Storage S3兼容对象存储（AWS S3、MinIO等），使用SigV4签名
+type S3Storage struct {
+	endpoint *url.URL
+	options  S3Options
+	client   *http.Client
+}
+
+// NewS3Storage 创建S3兼容对象存储
+func NewS3Storage(options S3Options) (*S3Storage, error) {
+	endpoint, err := url.Parse(options.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", options.Endpoint)
+	}
+	if options.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+
+	return &S3Storage{
+		endpoint: endpoint,
+		options:  options,
+		client: &http.Client{
+			// 下载可能持续很久，只限制建立连接和等待响应头的时间
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: 30 * time.Second,
+				MaxIdleConnsPerHost:   16,
+			},
+		},
+	}, nil
+}
+
+// Put 写入内容，size小于0时使用分片上传
+func (s *S3Storage) Put(key string, r io.Reader, size int64) error {
+	if size < 0 {
+		return s.putMultipart(key, r)
+	}
+
+	resp, err := s.do(http.MethodPut, key, nil, nil, r, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.error(resp)
+	}
+
+	return nil
+}
+
+// Get 读取完整内容
+func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
+	return s.GetRange(key, 0, -1)
+}
+
+// GetRange 读取从offset开始的length字节，length小于0表示读到末尾
+func (s *S3Storage) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if offset > 0 || length >= 0 {
+		if length == 0 {
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+		if length < 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		} else {
+			header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+		}
+	}
+
+	resp, err := s.do(http.MethodGet, key, nil, header, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		return nil, s.error(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// Stat 获取内容信息
+func (s *S3Storage) Stat(key string) (*BlobInfo, error) {
+	resp, err := s.do(http.MethodHead, key, nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.error(resp)
+	}
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &BlobInfo{
+		Key:     key,
+		Size:    resp.ContentLength,
+		ModTime: modTime,
+	}, nil
+}
+
+// Delete 删除内容，内容不存在时不返回错误
+func (s *S3Storage) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		if err := s.error(resp); err != ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Move 将内容移动到新的键，S3不支持重命名，通过复制后删除实现
+func (s *S3Storage) Move(src, dst string) error {
+	if src == "" {
+		return ErrInvalidKey
+	}
+
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", escapePath("/"+s.options.Bucket+"/"+strings.TrimPrefix(s.options.Prefix+src, "/")))
+
+	resp, err := s.do(http.MethodPut, dst, nil, header, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.error(resp)
+	}
+
+	return s.Delete(src)
+}
+
+// listBucketResult ListObjectsV2响应
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List 列出键以prefix开头的所有内容，使用ListObjectsV2分页读取
+func (s *S3Storage) List(prefix string) ([]*BlobInfo, error) {
+	base := strings.TrimPrefix(s.options.Prefix, "/")
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", base+prefix)
+
+	var blobs []*BlobInfo
+	for {
+		resp, err := s.send(http.MethodGet, "/", query, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s.error(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse s3 list response: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			blobs = append(blobs, &BlobInfo{
+				Key:     strings.TrimPrefix(object.Key, base),
+				Size:    object.Size,
+				ModTime: object.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return blobs, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// initiateMultipartUploadResult 初始化分片上传响应
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// completeMultipartUpload 完成分片上传请求
+type completeMultipartUpload struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+// completePart 已上传的分片
+type completePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putMultipart 分片上传长度未知的内容，每次只在内存中缓存一个分片
+func (s *S3Storage) putMultipart(key string, r io.Reader) error {
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return s.error(resp)
+	}
+
+	var initResult initiateMultipartUploadResult
+	err = xml.NewDecoder(resp.Body).Decode(&initResult)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode multipart upload response: %w", err)
+	}
+
+	uploadID := initResult.UploadID
+	if err := s.uploadParts(key, uploadID, r); err != nil {
+		// 失败时中止上传，释放已上传的分片
+		if resp, abortErr := s.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil, 0); abortErr == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	return nil
+}
+
+// uploadParts 上传所有分片并完成分片上传
+func (s *S3Storage) uploadParts(key, uploadID string, r io.Reader) error {
+	complete := completeMultipartUpload{}
+	buffer := make([]byte, s3PartSize)
+
+	for partNumber := 1; ; partNumber++ {
+		n, readErr := io.ReadFull(r, buffer)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return readErr
+		}
+		// 空内容也需要至少一个分片
+		if n == 0 && partNumber > 1 {
+			break
+		}
+
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadID},
+		}
+		resp, err := s.do(http.MethodPut, key, query, nil, bytes.NewReader(buffer[:n]), int64(n))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return s.error(resp)
+		}
+
+		complete.Parts = append(complete.Parts, completePart{
+			PartNumber: partNumber,
+			ETag:       resp.Header.Get("ETag"),
+		})
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return fmt.Errorf("failed to marshal multipart upload request: %w", err)
+	}
+
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 完成请求即使返回200也可能在响应体中携带错误
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read multipart upload response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || bytes.Contains(data, []byte("<Error>")) {
+		return fmt.Errorf("failed to complete multipart upload: %s", strings.TrimSpace(string(data)))
+	}
+
+	return nil
+}
+
+// s3Error S3错误响应
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// error 将非成功响应转换为错误
+func (s *S3Storage) error(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	var e s3Error
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := xml.Unmarshal(data, &e); err != nil || e.Code == "" {
+		return fmt.Errorf("s3 request failed: %s", resp.Status)
+	}
+	if e.Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+
+	return fmt.Errorf("s3 request failed: %s: %s", e.Code, e.Message)
+}
+
+// do 向key对应的对象发送签名后的请求
+func (s *S3Storage) do(method, key string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	if key == "" {
+		return nil, ErrInvalidKey
+	}
+
+	return s.send(method, "/"+strings.TrimPrefix(s.options.Prefix+key, "/"), query, header, body, size)
+}
+
+// send 发送签名后的请求，objectPath为存储桶内以/开头的路径，列出对象时为/
+func (s *S3Storage) send(method, objectPath string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	u := *s.endpoint
+	if s.options.PathStyle {
+		objectPath = "/" + s.options.Bucket + objectPath
+	} else {
+		u.Host = s.options.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + escapePath(objectPath)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	// 总是显式设置长度，长度为0的请求体会被net/http按chunked发送，签名的PUT请求会被S3拒绝
+	if body != nil && size == 0 {
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+	}
+	req.ContentLength = size
+
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 request failed: %w", err)
+	}
+
+	return resp, nil
+}
+
+// sign 使用AWS Signature Version 4签名请求
+func (s *S3Storage) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	// 参与签名的请求头
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lower := strings.ToLower(k)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + s.options.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.options.SecretKey), date)
+	key = hmacSHA256(key, s.options.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.options.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 计算HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath 按S3规则编码路径，保留分隔符
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery 生成按键排序并编码的查询字符串
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 按SigV4要求编码，仅保留非保留字符
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 内存中的S3兼容服务，只实现S3Storage用到的接口
+type fakeS3 struct {
+	t       *testing.T
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	nextID  int
+	mu      sync.Mutex
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Storage) {
+	t.Helper()
+
+	fake := &fakeS3{
+		t:       t,
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Storage(S3Options{
+		Endpoint:  server.URL,
+		Bucket:    "bucket",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "prefix/",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+
+	return fake, s
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		f.fail(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	// 签名的请求不允许使用chunked编码
+	if len(r.TransferEncoding) > 0 {
+		f.t.Errorf("%s %s sent with Transfer-Encoding %v", r.Method, r.URL.Path, r.TransferEncoding)
+		f.fail(w, http.StatusNotImplemented, "NotImplemented")
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/bucket/")
+	if !ok {
+		f.fail(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var complete completeMultipartUpload
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+			f.fail(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var data []byte
+		for _, part := range complete.Parts {
+			data = append(data, parts[part.PartNumber]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+		io.WriteString(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		f.list(w, query.Get("prefix"), query.Get("continuation-token"))
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number], _ = io.ReadAll(r.Body)
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		data, ok := f.objects[strings.TrimPrefix(source, "/bucket/")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		f.objects[key] = append([]byte(nil), data...)
+		io.WriteString(w, "<CopyObjectResult></CopyObjectResult>")
+
+	case r.Method == http.MethodPut:
+		if r.ContentLength < 0 {
+			f.fail(w, http.StatusLengthRequired, "MissingContentLength")
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Last-Modified", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		status := http.StatusOK
+		if spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes="); ok {
+			start, end, _ := strings.Cut(spec, "-")
+			from, _ := strconv.Atoi(start)
+			to := len(data) - 1
+			if end != "" {
+				to, _ = strconv.Atoi(end)
+			}
+			data = data[from : to+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case r.Method == http.MethodDelete:
+		if query.Has("uploadId") {
+			delete(f.uploads, query.Get("uploadId"))
+		} else {
+			delete(f.objects, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		f.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list 按键排序分页列出对象，每页最多两个，用于测试分页
+func (f *fakeS3) list(w http.ResponseWriter, prefix, after string) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	io.WriteString(w, "<ListBucketResult>")
+	for i, key := range keys {
+		if i == 2 {
+			fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>", keys[i-1])
+			break
+		}
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>1970-01-01T00:00:00Z</LastModified></Contents>", key, len(f.objects[key]))
+	}
+	io.WriteString(w, "</ListBucketResult>")
+}
+
+func (f *fakeS3) fail(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestS3PutGet(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		size int64
+	}{
+		{"empty", nil, 0},
+		{"small", []byte("hello, s3"), 9},
+		{"unknown length", []byte("streamed content"), -1},
+		{"unknown length empty", nil, -1},
+		{"multiple parts", bytes.Repeat([]byte("x"), s3PartSize+100), -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, s := newFakeS3(t)
+
+			// 包装一层，使net/http无法从读取器类型推断长度，与上传的临时文件一致
+			body := io.MultiReader(bytes.NewReader(tt.data))
+			if err := s.Put("sha256/blob", body, tt.size); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if keys := fake.keys(); len(keys) != 1 || keys[0] != "prefix/sha256/blob" {
+				t.Fatalf("stored keys = %v, want [prefix/sha256/blob]", keys)
+			}
+
+			r, err := s.Get("sha256/blob")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			got, _ := io.ReadAll(r)
+			r.Close()
+			if !bytes.Equal(got, tt.data) {
+				t.Fatalf("Get returned %d bytes, want %d", len(got), len(tt.data))
+			}
+
+			info, err := s.Stat("sha256/blob")
+			if err != nil {
+				t.Fatalf("Stat: %v", err)
+			}
+			if info.Size != int64(len(tt.data)) {
+				t.Fatalf("Stat size = %d, want %d", info.Size, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestS3GetRange(t *testing.T) {
+	_, s := newFakeS3(t)
+	if err := s.Put("blob", strings.NewReader("0123456789"), 10); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, -1, "3456789"},
+		{2, 3, "234"},
+		{0, 1, "0"},
+		{5, 0, ""},
+	}
+
+	for _, tt := range tests {
+		r, err := s.GetRange("blob", tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("GetRange(%d, %d): %v", tt.offset, tt.length, err)
+		}
+		got, _ := io.ReadAll(r)
+		r.Close()
+		if string(got) != tt.want {
+			t.Errorf("GetRange(%d, %d) = %q, want %q", tt.offset, tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestS3MoveDelete(t *testing.T) {
+	fake, s := newFakeS3(t)
+	if err := s.Put(".tmp/upload", strings.NewReader("content"), 7); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if err := s.Move(".tmp/upload", "sha256/digest"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if keys := fake.keys(); len(keys) != 1 || keys[0] != "prefix/sha256/digest" {
+		t.Fatalf("keys after Move = %v", keys)
+	}
+	if _, err := s.Get(".tmp/upload"); err != ErrNotFound {
+		t.Fatalf("Get moved source: err = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Delete("sha256/digest"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete("sha256/digest"); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+	if _, err := s.Stat("sha256/digest"); err != ErrNotFound {
+		t.Fatalf("Stat deleted: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Move("missing", "other"); err != ErrNotFound {
+		t.Fatalf("Move missing: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3List(t *testing.T) {
+	_, s := newFakeS3(t)
+	for _, key := range []string{"a/1", "a/2", "a/3", "a/4", "a/5.thumb-32", "b/1"} {
+		if err := s.Put(key, strings.NewReader(key), int64(len(key))); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"a/1", "a/2", "a/3", "a/4", "a/5.thumb-32", "b/1"}},
+		{"a/", []string{"a/1", "a/2", "a/3", "a/4", "a/5.thumb-32"}},
+		{"a/5", []string{"a/5.thumb-32"}},
+		{"c/", nil},
+	}
+
+	for _, tt := range tests {
+		blobs, err := s.List(tt.prefix)
+		if err != nil {
+			t.Fatalf("List(%q): %v", tt.prefix, err)
+		}
+		var got []string
+		for _, blob := range blobs {
+			got = append(got, blob.Key)
+			if blob.Size != int64(len(blob.Key)) {
+				t.Errorf("List(%q): %s size = %d, want %d", tt.prefix, blob.Key, blob.Size, len(blob.Key))
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("List(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
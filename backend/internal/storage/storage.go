@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// 存储驱动类型
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// Storage 文件内容存储接口
+type Storage interface {
+	// Put 写入内容，size小于0表示长度未知
+	Put(key string, r io.Reader, size int64) error
+	// Get 读取完整内容
+	Get(key string) (io.ReadCloser, error)
+	// GetRange 读取从offset开始的length字节，length小于0表示读到末尾
+	GetRange(key string, offset, length int64) (io.ReadCloser, error)
+	// Stat 获取内容信息
+	Stat(key string) (*BlobInfo, error)
+	// Delete 删除内容，内容不存在时不返回错误
+	Delete(key string) error
+	// Move 将内容移动到新的键，目标已存在时覆盖
+	Move(src, dst string) error
+	// List 列出键以prefix开头的所有内容
+	List(prefix string) ([]*BlobInfo, error)
+}
+
+// BlobInfo 存储内容信息
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// 错误定义
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
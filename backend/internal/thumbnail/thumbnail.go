@@ -0,0 +1,156 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels 允许解码的最大像素数，避免超大图片耗尽内存
+const maxPixels = 64 * 1024 * 1024
+
+// jpegQuality 缩略图JPEG编码质量
+const jpegQuality = 80
+
+// supportedMimetypes 支持生成缩略图的图片类型
+var supportedMimetypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// supportedExtensions 支持生成缩略图的文件扩展名，用于未提供MIME类型的旧文件
+var supportedExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// Supported 判断是否支持为该类型的文件生成缩略图
+func Supported(mimetype, ext string) bool {
+	return supportedMimetypes[mimetype] || supportedExtensions[ext]
+}
+
+// Generate 读取图片并生成长边不超过maxSize的缩略图，按EXIF方向信息旋转，
+// 不透明的图片编码为JPEG，带透明通道的编码为PNG，返回编码后的内容和MIME类型
+func Generate(r io.Reader, maxSize int) ([]byte, string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image: %w", err)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	var src image.Image
+	if format == "gif" {
+		// 动图只取第一帧
+		src, err = gif.Decode(bytes.NewReader(data))
+	} else {
+		src, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = exifOrientation(data)
+	}
+
+	dst := orient(resize(src, maxSize), orientation)
+
+	var buf bytes.Buffer
+	if dst.Opaque() {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, "", fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// resize 等比缩放到长边不超过maxSize，图片本身更小时不放大
+func resize(src image.Image, maxSize int) *image.NRGBA {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxSize > 0 && (width > maxSize || height > maxSize) {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// orient 按EXIF方向值（1-8）翻转或旋转图片
+func orient(src *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		// 5-8需要旋转90度，宽高互换
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = width-1-x, y
+			case 3: // 旋转180度
+				dx, dy = width-1-x, height-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, height-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = height-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = height-1-y, width-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, width-1-x
+			}
+			dst.SetNRGBA(dx, dy, src.NRGBAAt(x, y))
+		}
+	}
+
+	return dst
+}
+
+// 错误定义
+var (
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	ErrImageTooLarge    = errors.New("image dimensions too large")
+)
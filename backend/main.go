@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -10,74 +12,234 @@ import (
 
 	"cloud-clipboard/app/api"
 	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/auth"
 	"cloud-clipboard/internal/clipboard"
+	"cloud-clipboard/internal/events"
 	"cloud-clipboard/internal/file"
 	"cloud-clipboard/internal/logger"
+	"cloud-clipboard/internal/ratelimit"
+	"cloud-clipboard/internal/room"
+	"cloud-clipboard/internal/share"
+	"cloud-clipboard/internal/storage"
 )
 
 func main() {
 	// 加载配置
 	cfg := config.GetDefaultConfig()
 
+	// 令牌管理命令行，不启动服务
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		os.Exit(runTokenCommand(&cfg.Auth, os.Args[2:]))
+	}
+
 	// 初始化日志
 	if err := logger.InitLogger(logger.GetDefaultConfig()); err != nil {
 		fmt.Printf("Failed to initialize logger: %v\n", err)
 		return
 	}
 
-	// 初始化服务
-	cache := clipboard.NewLRUCache(cfg.Clipboard.MaxMemory, cfg.Clipboard.MaxItems)
+	// 初始化服务，每个命名空间使用独立的剪切板，公共命名空间在启动时打开
+	clipboards := clipboard.NewNamespaces(func(namespace string) (clipboard.Store, error) {
+		return newClipboardStore(&cfg.Clipboard, namespace)
+	}, func(namespace string) bool {
+		return clipboardStoreExists(&cfg.Clipboard, namespace)
+	})
+	if _, err := clipboards.Get(""); err != nil {
+		logger.Fatalf("Failed to initialize clipboard store: %v", err)
+	}
+	defer clipboards.Close()
 
-	fileService, err := file.NewFileService(cfg.File.UploadDir, cfg.File.MetadataFile)
+	blobStorage, err := newBlobStorage(&cfg.File)
+	if err != nil {
+		logger.Fatalf("Failed to initialize file storage: %v", err)
+	}
+
+	metadataStore, err := newMetadataStore(&cfg.File)
 	if err != nil {
 		logger.Fatalf("Failed to initialize file service: %v", err)
 	}
+	defer metadataStore.Close()
+
+	fileService, err := file.NewFileService(blobStorage, metadataStore, cfg.File.ThumbnailSize, cfg.File.PreviewSize, file.TypePolicy{
+		Allowed: cfg.File.AllowedTypes,
+		Denied:  cfg.File.DeniedTypes,
+	})
+	if err != nil {
+		logger.Fatalf("Failed to initialize file service: %v", err)
+	}
+
+	// 存储中不保留原始文件名，文件内容只能通过下载接口或分享链接访问
+	if migrated, err := fileService.MigrateLegacyBlobs(); err != nil {
+		logger.Fatalf("Failed to migrate legacy files: %v", err)
+	} else if migrated > 0 {
+		logger.Infof("Migrated %d legacy files to content-addressed storage.", migrated)
+	}
+
+	uploadManager, err := file.NewUploadManager(filepath.Join(cfg.File.UploadDir, ".tus"))
+	if err != nil {
+		logger.Fatalf("Failed to initialize upload sessions: %v", err)
+	}
+
+	tokenStore, err := auth.NewTokenStore(cfg.Auth.TokenFile)
+	if err != nil {
+		logger.Fatalf("Failed to initialize token store: %v", err)
+	}
+
+	shareSigner, err := share.NewSigner(cfg.Share.Secret, cfg.Share.KeyFile)
+	if err != nil {
+		logger.Fatalf("Failed to initialize share signing key: %v", err)
+	}
+	shareStore, err := share.NewStore(cfg.Share.LinkFile, shareSigner)
+	if err != nil {
+		logger.Fatalf("Failed to initialize share links: %v", err)
+	}
+
+	hub := events.NewHub(cfg.Events.HistorySize)
+
+	// 房间只保存在内存中，重启前房间内上传的文件已无法访问
+	if deleted, err := fileService.DeleteNamespaceFiles(room.IsNamespace); err != nil {
+		logger.Errorf("Failed to delete files of closed rooms: %v", err)
+	} else if len(deleted) > 0 {
+		logger.Infof("Deleted %d files of closed rooms.", len(deleted))
+	}
+	if _, err := shareStore.RemoveNamespace(room.IsNamespace); err != nil {
+		logger.Errorf("Failed to remove share links of closed rooms: %v", err)
+	}
+
+	// 房间关闭后删除房间内的剪切板、文件和分享链接
+	roomManager := room.NewManager(room.Options{
+		TTL:          cfg.Room.TTL,
+		MaxTTL:       cfg.Room.MaxTTL,
+		MaxRooms:     cfg.Room.MaxRooms,
+		MaxMembers:   cfg.Room.MaxMembers,
+		JoinAttempts: cfg.Room.JoinAttempts,
+		CodeAttempts: cfg.Room.CodeAttempts,
+	}, func(r *room.Room) {
+		namespace := r.Namespace()
+		if err := clipboards.Remove(namespace); err != nil {
+			logger.Errorf("Failed to close clipboard of room %s: %v", r.ID, err)
+		}
+		inRoom := func(owner string) bool {
+			return owner == namespace
+		}
+		deleted, err := fileService.DeleteNamespaceFiles(inRoom)
+		if err != nil {
+			logger.Errorf("Failed to delete files of room %s: %v", r.ID, err)
+		}
+		if _, err := shareStore.RemoveNamespace(inRoom); err != nil {
+			logger.Errorf("Failed to remove share links of room %s: %v", r.ID, err)
+		}
+		logger.Infof("Room %s closed, deleted %d files.", r.ID, len(deleted))
+		hub.Publish(namespace, events.TypeRoomClose, gin.H{"id": r.ID})
+	})
 
 	// 初始化控制器
-	clipboardController := api.NewClipboardController(cache, &cfg.Clipboard)
-	fileController := api.NewFileController(fileService, &cfg.File)
+	clipboardController := api.NewClipboardController(clipboards, hub, &cfg.Clipboard)
+	downloadLimiter := newRateLimiter(&cfg.RateLimit.Download)
+	uploadLimiter := newRateLimiter(&cfg.RateLimit.Upload)
+
+	fileController := api.NewFileController(fileService, hub, downloadLimiter, &cfg.File)
+	tusController := api.NewTusController(fileService, uploadManager, hub, &cfg.File)
+	eventController := api.NewEventController(hub, &cfg.Events)
+	adminController := api.NewAdminController(fileService, hub, downloadLimiter, uploadLimiter)
+	tokenController := api.NewTokenController(tokenStore)
+	roomController := api.NewRoomController(roomManager, hub, &cfg.Server)
+	shareController := api.NewShareController(shareStore, fileController, clipboardController, hub, &cfg.Share, &cfg.Server)
+	uploadRateLimit := api.RateLimitUpload(uploadLimiter)
+
+	// 认证和权限检查中间件
+	authenticate := api.Authenticate(tokenStore, cfg.Auth.AnonymousScopes)
+	joinedRoom := api.JoinedRoom(roomManager)
+	clipboardRead := api.RequireScope(auth.ScopeClipboardRead)
+	clipboardWrite := api.RequireScope(auth.ScopeClipboardWrite)
+	filesRead := api.RequireScope(auth.ScopeFilesRead)
+	filesWrite := api.RequireScope(auth.ScopeFilesWrite)
+	eventsRead := api.RequireScope(auth.ScopeClipboardRead, auth.ScopeFilesRead)
+	adminOnly := api.RequireScope(auth.ScopeAdmin)
+	roomCreate := api.RequireScope(auth.ScopeClipboardWrite, auth.ScopeFilesWrite)
+	sharesRead := api.RequireScope(auth.ScopeClipboardRead, auth.ScopeFilesRead)
+	sharesWrite := api.RequireScope(auth.ScopeClipboardWrite, auth.ScopeFilesWrite)
 
-	// 创建Gin引擎
+	// 创建Gin引擎，只信任配置的反向代理发送的X-Forwarded-For，否则客户端可以伪造IP绕过按IP的限速和配对码尝试次数限制
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatalf("Failed to configure trusted proxies: %v", err)
+	}
 
 	// 配置CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "HEAD", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Last-Event-ID", "Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset", "Digest", "X-Content-SHA256", "X-File-Password", "Authorization", "X-Room-Token"},
+		ExposeHeaders:    []string{"Content-Length", "Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-File-Id", "Digest", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// 静态文件服务
-	r.Static("/uploads", cfg.File.UploadDir)
-
-	// API路由
-	api := r.Group("/api")
+	// API路由，所有接口都经过认证中间件，按接口要求相应的权限；携带房间令牌的请求作用于房间
+	api := r.Group("/api", authenticate, joinedRoom)
 	{
 		// 字符串剪切板路由
 		clipboard := api.Group("/clipboard")
 		{
-			clipboard.POST("/text", clipboardController.UploadText)
-			clipboard.GET("/text", clipboardController.GetAllText)
-			clipboard.DELETE("/text", clipboardController.ClearAllText)
-			clipboard.GET("/text/:id", clipboardController.GetTextById)
-			clipboard.DELETE("/text/:id", clipboardController.DeleteTextById)
+			clipboard.POST("/text", clipboardWrite, clipboardController.UploadText)
+			clipboard.GET("/text", clipboardRead, clipboardController.GetAllText)
+			clipboard.DELETE("/text", adminOnly, clipboardController.ClearAllText)
+			clipboard.GET("/text/next", clipboardRead, clipboardController.WaitNextText)
+			clipboard.GET("/text/:id", clipboardRead, clipboardController.GetTextById)
+			clipboard.DELETE("/text/:id", clipboardWrite, clipboardController.DeleteTextById)
+			clipboard.POST("/text/:id/share", clipboardWrite, shareController.ShareText)
 		}
 
 		// 文件路由
 		files := api.Group("/files")
 		{
-			files.POST("", fileController.UploadFile)
-			files.GET("", fileController.GetAllFiles)
-			files.GET("/:id", fileController.GetFileInfo)
-			files.GET("/:id/download", fileController.DownloadFile)
-			files.GET("/:id/thumbnail", fileController.GetFileThumbnail)
-			files.DELETE("/:id", fileController.DeleteFile)
+			files.POST("", filesWrite, uploadRateLimit, fileController.UploadFile)
+			files.GET("", filesRead, fileController.GetAllFiles)
+			files.OPTIONS("/tus", tusController.Options)
+			files.POST("/tus", filesWrite, tusController.CreateUpload)
+			files.HEAD("/tus/:id", filesWrite, tusController.GetUploadOffset)
+			files.PATCH("/tus/:id", filesWrite, uploadRateLimit, tusController.PatchUpload)
+			files.DELETE("/tus/:id", filesWrite, tusController.DeleteUpload)
+			files.GET("/:id", filesRead, fileController.GetFileInfo)
+			files.GET("/:id/download", filesRead, fileController.DownloadFile)
+			files.GET("/:id/thumbnail", filesRead, fileController.GetFileThumbnail)
+			files.GET("/:id/preview", filesRead, fileController.PreviewFile)
+			files.DELETE("/:id", filesWrite, fileController.DeleteFile)
+			files.POST("/:id/share", filesWrite, shareController.ShareFile)
+		}
+
+		// 房间路由
+		rooms := api.Group("/rooms")
+		{
+			rooms.POST("", roomCreate, roomController.CreateRoom)
+			rooms.POST("/join", roomController.JoinRoom)
+			rooms.GET("/current", roomController.GetRoom)
+			rooms.DELETE("/current", roomController.LeaveRoom)
+		}
+
+		// 分享链接管理路由
+		api.GET("/shares", sharesRead, shareController.ListShares)
+		api.DELETE("/shares/:id", sharesWrite, shareController.RevokeShare)
+
+		// 实时事件路由
+		api.GET("/events", eventsRead, eventController.Stream)
+
+		// 管理路由
+		admin := api.Group("/admin", adminOnly)
+		{
+			admin.GET("/throughput", adminController.GetThroughput)
+			admin.GET("/storage", adminController.GetStorageUsage)
+			admin.POST("/scrub", adminController.ScrubFiles)
+			admin.GET("/tokens", tokenController.ListTokens)
+			admin.POST("/tokens", tokenController.CreateToken)
+			admin.DELETE("/tokens/:id", tokenController.RevokeToken)
 		}
 	}
 
+	// 分享链接兑换路由，不需要身份认证，由链接签名授权
+	r.GET("/s/:token", shareController.RedeemShare)
+
 	// 健康检查路由
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -100,6 +262,95 @@ func main() {
 				continue
 			}
 			logger.Infof("Cleanup completed. Deleted %d expired files.", deletedCount)
+			if deletedCount > 0 {
+				hub.Broadcast(events.TypeFileCleanup, gin.H{"deletedCount": deletedCount})
+			}
+
+			// 清理长时间未完成的断点续传上传
+			staleCount, err := uploadManager.CleanupStale(cfg.File.MaxAge)
+			if err != nil {
+				logger.Errorf("Failed to cleanup stale uploads: %v", err)
+			} else if staleCount > 0 {
+				logger.Infof("Removed %d stale upload sessions.", staleCount)
+			}
+		}
+	}()
+
+	// 设置文件完整性校验任务
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.File.ScrubInterval) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+			logger.Info("Running file scrub task...")
+			result, err := fileService.ScrubFiles()
+			if err != nil {
+				logger.Errorf("Failed to scrub files: %v", err)
+				continue
+			}
+			logger.Infof("Scrub completed. Checked %d files, %d corrupted.", result.Checked, len(result.Corrupted))
+			for owner, ids := range result.CorruptedByOwner() {
+				hub.Publish(owner, events.TypeFileCorrupt, gin.H{"ids": ids})
+			}
+		}
+	}()
+
+	// 设置限速器空闲令牌桶清理任务
+	go func() {
+		idleTimeout := time.Duration(cfg.RateLimit.IdleTimeout) * time.Millisecond
+		ticker := time.NewTicker(idleTimeout)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+			downloadLimiter.Prune(idleTimeout)
+			uploadLimiter.Prune(idleTimeout)
+		}
+	}()
+
+	// 设置剪切板过期清理任务
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.Clipboard.ExpireCheckInterval) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+			clipboards.Each(func(namespace string, cache clipboard.Store) {
+				if removedCount := cache.RemoveExpired(); removedCount > 0 {
+					logger.Infof("Removed %d expired clipboard items.", removedCount)
+					hub.Publish(namespace, events.TypeClipboardExpire, gin.H{"removedCount": removedCount})
+				}
+			})
+		}
+	}()
+
+	// 设置房间过期清理任务
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.Room.CheckInterval) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+			if closedCount := roomManager.RemoveExpired(); closedCount > 0 {
+				logger.Infof("Closed %d expired rooms.", closedCount)
+			}
+		}
+	}()
+
+	// 设置分享链接过期清理任务
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.Share.CheckInterval) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+			removedCount, err := shareStore.RemoveExpired()
+			if err != nil {
+				logger.Errorf("Failed to remove expired share links: %v", err)
+			} else if removedCount > 0 {
+				logger.Infof("Removed %d expired share links.", removedCount)
+			}
 		}
 	}()
 
@@ -109,16 +360,162 @@ func main() {
 	logger.Info("API endpoints:")
 	logger.Info("  POST   /api/clipboard/text      - Upload text")
 	logger.Info("  GET    /api/clipboard/text      - Get all text items")
+	logger.Info("  GET    /api/clipboard/text/next - Wait for next text item")
 	logger.Info("  GET    /api/clipboard/text/:id  - Get specific text item")
 	logger.Info("  DELETE /api/clipboard/text/:id  - Delete text item")
 	logger.Info("  DELETE /api/clipboard/text      - Clear all text items")
+	logger.Info("  POST   /api/clipboard/text/:id/share - Create share link for text item")
 	logger.Info("  POST   /api/files               - Upload file")
 	logger.Info("  GET    /api/files               - Get all files")
+	logger.Info("  POST   /api/files/tus           - Create resumable upload (tus)")
+	logger.Info("  PATCH  /api/files/tus/:id       - Upload chunk (tus)")
 	logger.Info("  GET    /api/files/:id           - Get file info")
 	logger.Info("  GET    /api/files/:id/download  - Download file")
+	logger.Info("  GET    /api/files/:id/preview   - Preview file inline")
 	logger.Info("  DELETE /api/files/:id           - Delete file")
+	logger.Info("  POST   /api/files/:id/share     - Create share link for file")
+	logger.Info("  GET    /api/shares              - List share links")
+	logger.Info("  DELETE /api/shares/:id          - Revoke share link")
+	logger.Info("  GET    /s/:token                - Redeem share link")
+	logger.Info("  POST   /api/rooms               - Create room")
+	logger.Info("  POST   /api/rooms/join          - Join room by pairing code")
+	logger.Info("  GET    /api/rooms/current       - Get current room")
+	logger.Info("  DELETE /api/rooms/current       - Leave room")
+	logger.Info("  GET    /api/events              - Subscribe to events (SSE/WebSocket)")
+	logger.Info("  GET    /api/admin/throughput    - Get bandwidth throughput")
+	logger.Info("  GET    /api/admin/storage       - Get logical and physical storage usage")
+	logger.Info("  POST   /api/admin/scrub         - Verify stored file checksums")
+	logger.Info("  GET    /api/admin/tokens        - List API tokens")
+	logger.Info("  POST   /api/admin/tokens        - Create API token")
+	logger.Info("  DELETE /api/admin/tokens/:id    - Revoke API token")
 
 	if err := r.Run(addr); err != nil {
 		logger.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// newRateLimiter 根据配置创建带宽限速器
+func newRateLimiter(cfg *config.BandwidthConfig) *ratelimit.Limiter {
+	return ratelimit.New(ratelimit.Config{
+		GlobalRate:  cfg.GlobalRate,
+		GlobalBurst: cfg.GlobalBurst,
+		ClientRate:  cfg.ClientRate,
+		ClientBurst: cfg.ClientBurst,
+	})
+}
+
+// newClipboardStore 根据配置创建命名空间的剪切板存储，公共命名空间（namespace为空）使用配置中的路径，
+// 其他命名空间的数据保存在同一目录下的namespaces子目录中；房间是临时的，只使用内存存储
+func newClipboardStore(cfg *config.ClipboardConfig, namespace string) (clipboard.Store, error) {
+	if room.IsNamespace(namespace) {
+		return clipboard.NewLRUCache(cfg.MaxMemory, cfg.MaxItems), nil
+	}
+
+	switch cfg.Store {
+	case clipboard.StoreBolt:
+		path := clipboardStorePath(cfg, namespace)
+		store, err := clipboard.NewBoltStore(path, cfg.MaxMemory, cfg.MaxItems)
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("Clipboard opened from %s with %d items", path, store.GetCount())
+		return store, nil
+	case clipboard.StoreMemory, "":
+		if !cfg.Persist {
+			return clipboard.NewLRUCache(cfg.MaxMemory, cfg.MaxItems), nil
+		}
+
+		dataDir := clipboardStorePath(cfg, namespace)
+		cache, err := clipboard.NewPersistentLRUCache(cfg.MaxMemory, cfg.MaxItems, dataDir)
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("Clipboard restored from %s with %d items", dataDir, cache.GetCount())
+
+		// 设置剪切板快照压缩任务
+		go func() {
+			ticker := time.NewTicker(time.Duration(cfg.SnapshotInterval) * time.Millisecond)
+			defer ticker.Stop()
+
+			for {
+				<-ticker.C
+				if err := cache.Compact(); err != nil {
+					logger.Errorf("Failed to compact clipboard journal: %v", err)
+				}
+			}
+		}()
+
+		return cache, nil
+	default:
+		return nil, fmt.Errorf("unknown clipboard store: %s", cfg.Store)
+	}
+}
+
+// clipboardStorePath 命名空间剪切板的持久化位置（bbolt数据库文件或日志目录），不持久化时返回空
+func clipboardStorePath(cfg *config.ClipboardConfig, namespace string) string {
+	if room.IsNamespace(namespace) {
+		return ""
+	}
+
+	switch cfg.Store {
+	case clipboard.StoreBolt:
+		if namespace == "" {
+			return cfg.BoltPath
+		}
+		return filepath.Join(filepath.Dir(cfg.BoltPath), "namespaces", namespace+filepath.Ext(cfg.BoltPath))
+	case clipboard.StoreMemory, "":
+		if !cfg.Persist {
+			return ""
+		}
+		if namespace == "" {
+			return cfg.DataDir
+		}
+		return filepath.Join(cfg.DataDir, "namespaces", namespace)
+	default:
+		return ""
+	}
+}
+
+// clipboardStoreExists 命名空间的剪切板是否已持久化，不持久化的剪切板只存在于已打开的存储中
+func clipboardStoreExists(cfg *config.ClipboardConfig, namespace string) bool {
+	path := clipboardStorePath(cfg, namespace)
+	if path == "" {
+		return false
+	}
+
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// newBlobStorage 根据配置创建文件内容存储
+func newBlobStorage(cfg *config.FileConfig) (storage.Storage, error) {
+	switch cfg.Storage {
+	case storage.DriverLocal, "":
+		return storage.NewLocalStorage(cfg.UploadDir)
+	case storage.DriverS3:
+		return storage.NewS3Storage(storage.S3Options{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			Prefix:    cfg.S3.Prefix,
+			PathStyle: cfg.S3.PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown file storage: %s", cfg.Storage)
+	}
+}
+
+// newMetadataStore 根据配置创建文件元数据存储
+func newMetadataStore(cfg *config.FileConfig) (file.MetadataStore, error) {
+	switch cfg.MetadataStore {
+	case file.MetadataStoreBolt, "":
+		// 首次启动时导入旧版files.json
+		return file.NewBoltMetadataStore(cfg.MetadataDB, cfg.MetadataFile, cfg.MetadataBackups)
+	case file.MetadataStoreJSON:
+		return file.NewJSONMetadataStore(cfg.MetadataFile, cfg.MetadataBackups)
+	default:
+		return nil, fmt.Errorf("unknown metadata store: %s", cfg.MetadataStore)
+	}
+}
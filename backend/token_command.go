@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"cloud-clipboard/app/config"
+	"cloud-clipboard/internal/auth"
+)
+
+// tokenUsage 令牌管理命令的用法
+const tokenUsage = `Usage:
+  %[1]s token create -name NAME -scopes SCOPE[,SCOPE...] [-namespace NAME] [-expires DURATION]
+  %[1]s token list
+  %[1]s token revoke ID
+
+Scopes: %[2]s
+Namespace defaults to the token ID (a private space); use "%[3]s" for the shared public space.
+`
+
+// runTokenCommand 执行令牌管理命令，直接读写令牌文件，运行中的服务会自动加载变化，返回进程退出码
+func runTokenCommand(cfg *config.AuthConfig, args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, tokenUsage, os.Args[0], strings.Join(auth.Scopes, ", "), auth.PublicNamespace)
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	store, err := auth.NewTokenStore(cfg.TokenFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open token store: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("token create", flag.ContinueOnError)
+		name := flags.String("name", "", "token name")
+		scopes := flags.String("scopes", "", "comma separated scopes")
+		namespace := flags.String("namespace", "", "namespace shared with other tokens (default: the token's own)")
+		expires := flags.Duration("expires", 0, "token lifetime, e.g. 720h (0 means never)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if *name == "" || *scopes == "" || *expires < 0 {
+			usage()
+			return 2
+		}
+
+		secret, token, err := store.Create(*name, *namespace, strings.Split(*scopes, ","), expires.Milliseconds())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create token: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Created token %s (%s) with scopes %s in namespace %s\n", token.ID, token.Name, strings.Join(token.Scopes, ","), token.NamespaceName())
+		fmt.Printf("Token (shown only once): %s\n", secret)
+
+	case "list":
+		tokens, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list tokens: %v\n", err)
+			return 1
+		}
+		fmt.Printf("%-8s  %-20s  %-20s  %-20s  %-40s\n", "ID", "NAME", "NAMESPACE", "EXPIRES", "SCOPES")
+		for _, token := range tokens {
+			expiresAt := "never"
+			if token.ExpiresAt > 0 {
+				expiresAt = time.UnixMilli(token.ExpiresAt).Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-8s  %-20s  %-20s  %-20s  %-40s\n", token.ID, token.Name, token.NamespaceName(), expiresAt, strings.Join(token.Scopes, ","))
+		}
+
+	case "revoke":
+		if len(args) != 2 {
+			usage()
+			return 2
+		}
+		if err := store.Revoke(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to revoke token: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Revoked token %s\n", args[1])
+
+	default:
+		usage()
+		return 2
+	}
+
+	return 0
+}